@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Waveforms shown in the export audio dialog
+const (
+	WaveSine   = "Sine"
+	WaveSquare = "Square"
+	WaveSaw    = "Saw"
+)
+
+var waveforms = []string{WaveSine, WaveSquare, WaveSaw}
+
+// Settings for the built in synthesizer
+// Attack, decay and release are in seconds, sustain is the level held between decay and release, from 0 to 1
+type SynthOptions struct {
+	Waveform   string
+	SampleRate int
+	Attack     float64
+	Decay      float64
+	Sustain    float64
+	Release    float64
+}
+
+// Renders the notes to mono audio samples using a simple synthesizer, with the timing from the tempo map
+// Every note is a single oscillator with an ADSR envelope, and its velocity sets the volume
+// The result is normalized so the loudest sample is just below full scale
+func renderAudio(notes []Note, tempos TempoMap, opts SynthOptions) []float32 {
+	var (
+		rate    = float64(opts.SampleRate)
+		length  int
+		samples []float32
+	)
+
+	// work out how long the audio is, including the release of the last note
+	for _, note := range notes {
+		end := int((tempos.Seconds(note.End) + opts.Release) * rate)
+		if end > length {
+			length = end
+		}
+	}
+	samples = make([]float32, length+1)
+
+	// a single cycle of the waveform, looked up instead of calculated for every sample
+	var table [4096]float32
+	for i := range table {
+		table[i] = float32(oscillator(opts.Waveform, float64(i)/float64(len(table))))
+	}
+
+	for _, note := range notes {
+		var (
+			start    = tempos.Seconds(note.Start)
+			duration = tempos.Seconds(note.End) - start
+			step     = 440 * math.Pow(2, (float64(note.Key)-69)/12) / rate // phase change per sample
+			volume   = float64(note.Velocity) / 127
+			first    = int(start * rate)
+			count    = int((duration + opts.Release) * rate)
+			phase    float64
+		)
+
+		for i := 0; i < count && first+i < len(samples); i++ {
+			samples[first+i] += table[int(phase*float64(len(table)))] * float32(envelope(float64(i)/rate, duration, opts)*volume)
+
+			phase += step
+			if phase >= 1 {
+				phase -= 1
+			}
+		}
+	}
+
+	// normalize
+	var peak float32
+	for _, sample := range samples {
+		if sample > peak {
+			peak = sample
+		} else if -sample > peak {
+			peak = -sample
+		}
+	}
+	if peak > 0 {
+		for i := range samples {
+			samples[i] = samples[i] / peak * 0.9
+		}
+	}
+
+	return samples
+}
+
+// Gets the value of a waveform at a phase between 0 and 1
+func oscillator(waveform string, phase float64) float64 {
+	switch waveform {
+	case WaveSquare:
+		if phase < 0.5 {
+			return 1
+		}
+		return -1
+	case WaveSaw:
+		return phase*2 - 1
+	default:
+		return math.Sin(phase * 2 * math.Pi)
+	}
+}
+
+// Gets the volume of the ADSR envelope at t seconds into a note which is held for duration seconds
+func envelope(t float64, duration float64, opts SynthOptions) float64 {
+	if t < duration {
+		return heldLevel(t, opts)
+	}
+
+	// released, fade out from wherever the envelope was when the note ended
+	if opts.Release <= 0 || t >= duration+opts.Release {
+		return 0
+	}
+	return heldLevel(duration, opts) * (1 - (t-duration)/opts.Release)
+}
+
+// Gets the volume of the envelope t seconds into a note which is still held
+func heldLevel(t float64, opts SynthOptions) float64 {
+	if t < opts.Attack {
+		return t / opts.Attack
+	}
+	if t < opts.Attack+opts.Decay {
+		return 1 - (1-opts.Sustain)*(t-opts.Attack)/opts.Decay
+	}
+	return opts.Sustain
+}
+
+// Writes the samples as a 16 bit mono wav file
+func encodeWAV(w io.Writer, samples []float32, sampleRate int) error {
+	const (
+		channels      = 1
+		bitsPerSample = 16
+	)
+
+	var (
+		buf       = bufio.NewWriter(w)
+		dataSize  = uint32(len(samples) * bitsPerSample / 8)
+		byteRate  = uint32(sampleRate * channels * bitsPerSample / 8)
+		alignment = uint16(channels * bitsPerSample / 8)
+	)
+
+	// riff header, then the format chunk, then the data chunk
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, 36+dataSize)
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(buf, binary.LittleEndian, uint32(16))
+	binary.Write(buf, binary.LittleEndian, uint16(1)) // pcm
+	binary.Write(buf, binary.LittleEndian, uint16(channels))
+	binary.Write(buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(buf, binary.LittleEndian, byteRate)
+	binary.Write(buf, binary.LittleEndian, alignment)
+	binary.Write(buf, binary.LittleEndian, uint16(bitsPerSample))
+
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, dataSize)
+	sampleBytes := make([]byte, 2)
+	for _, sample := range samples {
+		binary.LittleEndian.PutUint16(sampleBytes, uint16(int16(sample*math.MaxInt16)))
+		buf.Write(sampleBytes)
+	}
+
+	return buf.Flush()
+}
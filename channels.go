@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Channel strategies shown in the settings dialog
+const (
+	ChannelFromSetting = "Note Channel"     // the channel, or round robin, picked in the Note Channel setting
+	ChannelSubset      = "Subset"           // round robin over the listed channels, one channel per track
+	ChannelRandomTrack = "Random Per Track" // a random listed channel for every track
+	ChannelKeyRanges   = "Key Ranges"       // a channel for every range of keys, like a split keyboard
+	ChannelRandomNote  = "Random Per Note"  // a random listed channel for every note
+	ChannelWeighted    = "Weighted"         // a random listed channel for every note, some more likely than others
+
+	// notes keep the channel they already have, for imported notes which keep their channels
+	// this is not shown in the settings
+	ChannelKeep = "Keep"
+)
+
+var channelStrategies = []string{ChannelFromSetting, ChannelSubset, ChannelRandomTrack, ChannelKeyRanges, ChannelRandomNote, ChannelWeighted}
+
+// How channels are given to tracks and notes
+type ChannelStrategy struct {
+	Strategy string
+	Channels []uint8    // channels to pick from, 0 - 15
+	Weights  []int      // how likely each of the channels is, for weighted
+	Ranges   []KeyRange // channels of each range of keys, for key ranges
+}
+
+// A range of keys, both included, and the channel its notes go on
+type KeyRange struct {
+	MinKey  uint8
+	MaxKey  uint8
+	Channel uint8
+}
+
+// Parses the channels of a strategy, as a comma separated list of channels from 1 to 16
+// Weighted channels can have a weight after a colon, e.g. "1:3, 2:1", and key ranges are written as
+// keys and a channel, e.g. "0-59:1, 60-127:2"
+func parseChannelStrategy(strategy string, text string) (ChannelStrategy, error) {
+	parsed := ChannelStrategy{Strategy: strategy}
+	if strategy == ChannelFromSetting || strategy == "" {
+		return parsed, nil
+	}
+
+	parseChannel := func(field string) (uint8, error) {
+		channel, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || channel < 1 || channel > 16 {
+			return 0, fmt.Errorf("%q is not a channel from 1 to 16", strings.TrimSpace(field))
+		}
+		return uint8(channel - 1), nil
+	}
+
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		switch strategy {
+		case ChannelKeyRanges:
+			keys, channelField, ok := strings.Cut(item, ":")
+			minField, maxField, isRange := strings.Cut(keys, "-")
+			if !ok || !isRange {
+				return parsed, fmt.Errorf("%q is not a key range and channel, e.g. 0-59:1", item)
+			}
+			minKey, minErr := strconv.Atoi(strings.TrimSpace(minField))
+			maxKey, maxErr := strconv.Atoi(strings.TrimSpace(maxField))
+			if minErr != nil || maxErr != nil || minKey < 0 || maxKey > 127 || minKey > maxKey {
+				return parsed, fmt.Errorf("%q is not a key range from 0 to 127", keys)
+			}
+			channel, err := parseChannel(channelField)
+			if err != nil {
+				return parsed, err
+			}
+			parsed.Ranges = append(parsed.Ranges, KeyRange{uint8(minKey), uint8(maxKey), channel})
+		case ChannelWeighted:
+			channelField, weightField, hasWeight := strings.Cut(item, ":")
+			channel, err := parseChannel(channelField)
+			if err != nil {
+				return parsed, err
+			}
+			weight := 1
+			if hasWeight {
+				if weight, err = strconv.Atoi(strings.TrimSpace(weightField)); err != nil || weight < 0 {
+					return parsed, fmt.Errorf("%q is not a weight", strings.TrimSpace(weightField))
+				}
+			}
+			parsed.Channels = append(parsed.Channels, channel)
+			parsed.Weights = append(parsed.Weights, weight)
+		default:
+			channel, err := parseChannel(item)
+			if err != nil {
+				return parsed, err
+			}
+			parsed.Channels = append(parsed.Channels, channel)
+		}
+	}
+
+	if len(parsed.Channels) == 0 && len(parsed.Ranges) == 0 {
+		return parsed, errors.New("no channels given")
+	}
+	if strategy == ChannelWeighted {
+		total := 0
+		for _, weight := range parsed.Weights {
+			total += weight
+		}
+		if total == 0 {
+			return parsed, errors.New("every weight is 0")
+		}
+	}
+	return parsed, nil
+}
+
+// Gets the channel of the next track
+// Strategies which pick a channel for every note use the Note Channel setting for the track, which is kept by
+// notes outside of every key range
+func (strategy ChannelStrategy) trackChannel(noteChannel string, trackCount *int) uint8 {
+	switch strategy.Strategy {
+	case ChannelSubset:
+		return strategy.Channels[*trackCount%len(strategy.Channels)]
+	case ChannelRandomTrack:
+		return strategy.Channels[rand.Intn(len(strategy.Channels))]
+	}
+	return uint8(trackChannel(parseNoteChannel(noteChannel), trackCount))
+}
+
+// Checks if the strategy picks a channel for every note, instead of for every track
+func (strategy ChannelStrategy) perNote() bool {
+	switch strategy.Strategy {
+	case ChannelKeyRanges, ChannelRandomNote, ChannelWeighted, ChannelKeep:
+		return true
+	}
+	return false
+}
+
+// Gives every note its own channel, for the strategies which pick a channel for every note
+func (strategy ChannelStrategy) assignNoteChannels(notes []Note) {
+	switch strategy.Strategy {
+	case ChannelKeyRanges:
+		for i := range notes {
+			for _, keyRange := range strategy.Ranges {
+				if notes[i].Key >= keyRange.MinKey && notes[i].Key <= keyRange.MaxKey {
+					notes[i].Channel = keyRange.Channel
+					break
+				}
+			}
+		}
+	case ChannelRandomNote:
+		for i := range notes {
+			notes[i].Channel = strategy.Channels[rand.Intn(len(strategy.Channels))]
+		}
+	case ChannelWeighted:
+		total := 0
+		for _, weight := range strategy.Weights {
+			total += weight
+		}
+		for i := range notes {
+			target := rand.Intn(total)
+			for j, weight := range strategy.Weights {
+				if target < weight {
+					notes[i].Channel = strategy.Channels[j]
+					break
+				}
+				target -= weight
+			}
+		}
+	}
+}
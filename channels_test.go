@@ -0,0 +1,63 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseChannelStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		text     string
+		want     ChannelStrategy
+		err      string
+	}{
+		{"note channel", ChannelFromSetting, "not used", ChannelStrategy{Strategy: ChannelFromSetting}, ""},
+		{"subset", ChannelSubset, "1, 2,16", ChannelStrategy{Strategy: ChannelSubset, Channels: []uint8{0, 1, 15}}, ""},
+		{"weighted", ChannelWeighted, "1:3, 2", ChannelStrategy{Strategy: ChannelWeighted, Channels: []uint8{0, 1}, Weights: []int{3, 1}}, ""},
+		{"key ranges", ChannelKeyRanges, "0-59:1, 60-127:2", ChannelStrategy{Strategy: ChannelKeyRanges, Ranges: []KeyRange{{0, 59, 0}, {60, 127, 1}}}, ""},
+		{"channel out of range", ChannelSubset, "0,17", ChannelStrategy{}, `"0" is not a channel`},
+		{"no channels", ChannelRandomNote, " , ", ChannelStrategy{}, "no channels given"},
+		{"no weight", ChannelWeighted, "1:0", ChannelStrategy{}, "every weight is 0"},
+		{"bad key range", ChannelKeyRanges, "60-10:1", ChannelStrategy{}, "not a key range from 0 to 127"},
+		{"missing channel", ChannelKeyRanges, "0-59", ChannelStrategy{}, "not a key range and channel"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseChannelStrategy(test.strategy, test.text)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAssignNoteChannels(t *testing.T) {
+	strategy, err := parseChannelStrategy(ChannelKeyRanges, "0-59:1, 60-127:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notes := []Note{{Key: 10, Channel: 5}, {Key: 59, Channel: 5}, {Key: 60, Channel: 5}, {Key: 127, Channel: 5}}
+	strategy.assignNoteChannels(notes)
+
+	var got []uint8
+	for _, note := range notes {
+		got = append(got, note.Channel)
+	}
+	if want := []uint8{0, 0, 1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got channels %v, want %v", got, want)
+	}
+}
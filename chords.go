@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Chords shown in the settings dialog, besides the chord types
+const (
+	ChordNone    = "None"
+	ChordRandom  = "Random Type" // a random chord type for every chord
+	ChordCluster = "Cluster"     // random keys within the spread
+)
+
+// Chord types, as semitones above the root
+var chordTypes = map[string][]int{
+	"Major":        {0, 4, 7},
+	"Minor":        {0, 3, 7},
+	"Diminished":   {0, 3, 6},
+	"Augmented":    {0, 4, 8},
+	"Sus2":         {0, 2, 7},
+	"Sus4":         {0, 5, 7},
+	"Dominant 7th": {0, 4, 7, 10},
+	"Major 7th":    {0, 4, 7, 11},
+	"Minor 7th":    {0, 3, 7, 10},
+}
+
+// Chord type names in the order shown in the settings dialog
+var chordTypeNames = []string{"Major", "Minor", "Diminished", "Augmented", "Sus2", "Sus4", "Dominant 7th", "Major 7th", "Minor 7th"}
+
+var chords = append(append([]string{ChordNone}, chordTypeNames...), ChordRandom, ChordCluster)
+
+// Settings for generating chords instead of single notes
+type ChordOptions struct {
+	Chord  string
+	Size   int // number of notes in each chord, chord types repeat an octave up if there are more notes than the type has
+	Spread int // largest number of semitones between the lowest and highest note of a chord
+}
+
+// Creates notes as chords, where every note of a chord has the same start, end and velocity
+// The roots are placed the same way as single notes, and every note of a chord counts towards noteCount,
+// so the last chord has fewer notes if needed to keep the count exact
+func chordNotes(noteCount int, opts GenOptions, channel uint8, roots func(noteCount int) []Note) []Note {
+	var (
+		chord = opts.Chords
+		notes []Note
+		size  = chord.Size
+	)
+	if size < 1 {
+		size = 1
+	}
+
+	for len(notes) < noteCount {
+		// enough roots for the notes left, if every chord has all of its notes
+		remaining := noteCount - len(notes)
+		for _, root := range roots((remaining + size - 1) / size) {
+			for _, offset := range chordShape(chord) {
+				key := int(root.Key) + offset
+				if key > 127 || len(notes) == noteCount {
+					break
+				}
+
+				note := root
+				note.Key = uint8(key)
+				notes = append(notes, note)
+			}
+		}
+	}
+
+	return notes
+}
+
+// Gets the semitones above the root of every note of a chord, from lowest to highest
+func chordShape(chord ChordOptions) []int {
+	var (
+		size      = chord.Size
+		spread    = chord.Spread
+		chordType = chord.Chord
+	)
+	if size < 1 {
+		size = 1
+	}
+	if spread < 0 {
+		spread = 0
+	}
+
+	if chordType == ChordCluster {
+		// pick random keys within the spread, always including the root
+		keys := rand.Perm(spread)
+		if size-1 < len(keys) {
+			keys = keys[:size-1]
+		}
+		shape := []int{0}
+		for _, key := range keys {
+			shape = append(shape, key+1)
+		}
+		sort.Ints(shape)
+		return shape
+	}
+
+	if chordType == ChordRandom {
+		chordType = chordTypeNames[rand.Intn(len(chordTypeNames))]
+	}
+	intervals, ok := chordTypes[chordType]
+	if !ok {
+		return []int{0}
+	}
+
+	// repeat the chord an octave up until it has enough notes, leaving out anything past the spread
+	var shape []int
+	for i := 0; len(shape) < size; i++ {
+		offset := intervals[i%len(intervals)] + 12*(i/len(intervals))
+		if offset > spread {
+			break
+		}
+		shape = append(shape, offset)
+	}
+	return shape
+}
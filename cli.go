@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Split modes accepted by the -split flag
+var cliSplitModes = map[string]string{
+	"none":   SplitNone,
+	"tracks": SplitTracks,
+	"notes":  SplitNotes,
+	"size":   SplitSize,
+}
+
+// Generates a midi from the options given as flags, without opening the gui
+// Returns the exit status, 0 if the midi was saved and verified, 1 if it could not be saved or does not match what
+// was generated, and 2 if the flags are invalid
+func runCLI(args []string) int {
+	flags := flag.NewFlagSet("Random-Note-Generator", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: Random-Note-Generator [flags]")
+		fmt.Fprintln(flags.Output(), "Generates a midi without opening the gui. Run without any flags to open the gui.")
+		flags.PrintDefaults()
+	}
+
+	var (
+		output         = flags.String("output", "output.mid", "path of the midi to save")
+		force          = flags.Bool("force", false, "overwrite any files which already exist")
+		ppq            = flags.Int("ppq", 960, "ppq of the midi")
+		bpm            = flags.Int("bpm", 120, "bpm of the midi")
+		ticks          = flags.Int("ticks", 122880, "length of the midi in ticks")
+		bars           = flags.Int("bars", 0, "length of the midi in bars, used instead of -ticks if set")
+		noteCount      = flags.Int("notes", 20000, "number of notes to generate")
+		minNoteLength  = flags.Int("min-length", 960, "shortest a note can be, in ticks")
+		maxNoteLength  = flags.Int("max-length", 1920, "longest a note can be, in ticks")
+		notesPerTrack  = flags.Int("notes-per-track", 1000, "most notes in a single track")
+		trimNotes      = flags.Bool("trim", true, "cut off notes which go past the length of the midi")
+		minVelocity    = flags.Int("min-velocity", 50, "lowest note velocity, 1 - 127")
+		maxVelocity    = flags.Int("max-velocity", 100, "highest note velocity, 1 - 127")
+		channelFlag    = flags.String("channel", "16", "channel of the notes, 1 - 16, all, or all-skip-drums")
+		splitFlag      = flags.String("split", "none", "split the output into parts by tracks, notes, or size (in megabytes)")
+		splitLimitFlag = flags.Int("split-limit", 1000, "most tracks, notes, or megabytes in each part")
+		statsPath      = flags.String("stats", "", "save statistics of the midi as json, - for stdout")
+		analyzePath    = flags.String("analyze", "", "only show the statistics of an existing midi, as json")
+		imagePath      = flags.String("export-image", "", "save a piano roll of the notes as a png, or svg if the path ends in .svg")
+		imageWidth     = flags.Int("image-width", 1920, "width of the piano roll image")
+		imageHeight    = flags.Int("image-height", 1080, "height of the piano roll image")
+		imageMinKey    = flags.Int("image-min-key", 0, "lowest key shown in the piano roll image")
+		imageMaxKey    = flags.Int("image-max-key", 127, "highest key shown in the piano roll image")
+		imageColorBy   = flags.String("image-color-by", "track", "color the piano roll image by track or channel")
+		imageColors    = flags.String("image-colors", formatColors(defaultColors), "comma separated colors of the tracks or channels in the piano roll image")
+		audioPath      = flags.String("export-audio", "", "render the notes to a wav file")
+		waveform       = flags.String("waveform", "sine", "waveform of the synthesizer, sine, square or saw")
+		sampleRate     = flags.Int("sample-rate", 44100, "sample rate of the wav file")
+		attack         = flags.Int("attack", 10, "attack of the synthesizer, in milliseconds")
+		decay          = flags.Int("decay", 100, "decay of the synthesizer, in milliseconds")
+		sustain        = flags.Int("sustain", 70, "sustain level of the synthesizer, 0 - 100%")
+		release        = flags.Int("release", 200, "release of the synthesizer, in milliseconds")
+	)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// analyzing an existing midi does not generate anything
+	if *analyzePath != "" {
+		if *statsPath == "" {
+			*statsPath = "-"
+		}
+
+		midiData, err := smf.ReadFile(*analyzePath)
+		if err == nil {
+			var stats Stats
+			if stats, err = analyzeMIDI(midiData); err == nil {
+				err = writeStats(*statsPath, stats)
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	// check the flags the same way the gui checks its inputs
+	var errors []string
+	noteChannel, err := cliNoteChannel(*channelFlag)
+	if err != nil {
+		errors = append(errors, "channel: "+err.Error())
+	}
+	splitMode, ok := cliSplitModes[strings.ToLower(*splitFlag)]
+	if !ok {
+		errors = append(errors, fmt.Sprintf("split: %q is not none, tracks, notes or size", *splitFlag))
+	}
+	if *ppq < 1 || *ppq > 32767 {
+		errors = append(errors, "ppq: must be from 1 to 32767")
+	}
+	if *bpm < 1 {
+		errors = append(errors, "bpm: must be at least 1")
+	}
+	if *bars > 0 {
+		*ticks = *bars * *ppq * 4
+	}
+	if *ticks < 1 {
+		errors = append(errors, "ticks: must be at least 1")
+	}
+	if *noteCount < 0 {
+		errors = append(errors, "notes: cannot be negative")
+	}
+	if *minNoteLength < 0 || *minNoteLength > *maxNoteLength {
+		errors = append(errors, "note length: min must be at least 0, and not greater than max")
+	}
+	if *notesPerTrack < 1 {
+		errors = append(errors, "notes per track: must be at least 1")
+	}
+	if *minVelocity < 1 || *maxVelocity > 127 || *minVelocity > *maxVelocity {
+		errors = append(errors, "velocity: must be from 1 to 127, with min not greater than max")
+	}
+	renderOpts := defaultRenderOptions(*imageWidth, *imageHeight)
+	if *imagePath != "" {
+		if renderOpts.Colors, err = parseColors(*imageColors); err != nil {
+			errors = append(errors, "image colors: "+err.Error())
+		}
+		if *imageWidth < 1 || *imageHeight < 1 {
+			errors = append(errors, "image size: must be at least 1 by 1")
+		}
+		if *imageMinKey < 0 || *imageMaxKey > 127 || *imageMinKey > *imageMaxKey {
+			errors = append(errors, "image keys: must be from 0 to 127, with the lowest not greater than the highest")
+		}
+		if *imageColorBy != "track" && *imageColorBy != "channel" {
+			errors = append(errors, fmt.Sprintf("image color by: %q is not track or channel", *imageColorBy))
+		}
+		renderOpts.MinKey = uint8(*imageMinKey)
+		renderOpts.MaxKey = uint8(*imageMaxKey)
+		renderOpts.ByChannel = *imageColorBy == "channel"
+	}
+	synthOpts := SynthOptions{
+		SampleRate: *sampleRate,
+		Attack:     float64(*attack) / 1000,
+		Decay:      float64(*decay) / 1000,
+		Sustain:    float64(*sustain) / 100,
+		Release:    float64(*release) / 1000,
+	}
+	if *audioPath != "" {
+		for _, name := range waveforms {
+			if strings.EqualFold(name, *waveform) {
+				synthOpts.Waveform = name
+			}
+		}
+		if synthOpts.Waveform == "" {
+			errors = append(errors, fmt.Sprintf("waveform: %q is not sine, square or saw", *waveform))
+		}
+		if *sampleRate < 1 {
+			errors = append(errors, "sample rate: must be at least 1")
+		}
+		if *attack < 0 || *decay < 0 || *release < 0 || *sustain < 0 || *sustain > 100 {
+			errors = append(errors, "envelope: times cannot be negative, and sustain must be from 0 to 100")
+		}
+	}
+	if len(errors) > 0 {
+		fmt.Fprintln(os.Stderr, "invalid options:\n"+strings.Join(errors, "\n"))
+		return 2
+	}
+
+	logf("creating tracks | nc: %d | len: %d | maxlen: %d | minlen: %d | notesper: %d | trimnotes: %t | velocity: %d-%d | channel: %v", *noteCount, *ticks, *maxNoteLength, *minNoteLength, *notesPerTrack, *trimNotes, *minVelocity, *maxVelocity, noteChannel)
+	opts := GenOptions{
+		NoteCount:        *noteCount,
+		Ticks:            *ticks,
+		MinNoteLength:    *minNoteLength,
+		MaxNoteLength:    *maxNoteLength,
+		MaxNotesPerTrack: *notesPerTrack,
+		TrimNotes:        *trimNotes,
+		MinVelocity:      *minVelocity,
+		MaxVelocity:      *maxVelocity,
+		NoteChannel:      noteChannel,
+	}
+	tracks, err := createTracks(opts, logf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	parts := splitTracks(tracks, SplitPolicy{Mode: splitMode, Limit: *splitLimitFlag})
+
+	if *statsPath != "" {
+		stats, err := analyzeMIDI(buildMIDI(*ppq, *bpm, tracks))
+		if err == nil {
+			err = writeStats(*statsPath, stats)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	notes := collectNotes(buildMIDI(*ppq, *bpm, tracks))
+	if *imagePath != "" {
+		encode := encodePianoRollPNG
+		if strings.ToLower(filepath.Ext(*imagePath)) == ".svg" {
+			encode = encodePianoRollSVG
+		}
+
+		err := writeFileAtomic(*imagePath, func(w io.Writer) error {
+			return encode(w, notes, renderOpts)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		logf("saved the piano roll to %s", *imagePath)
+	}
+
+	if *audioPath != "" {
+		logf("rendering audio | waveform: %s | sample rate: %d", synthOpts.Waveform, synthOpts.SampleRate)
+		samples := renderAudio(notes, newConstantTempoMap(*ppq, *bpm), synthOpts)
+		err := writeFileAtomic(*audioPath, func(w io.Writer) error {
+			return encodeWAV(w, samples, synthOpts.SampleRate)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		logf("saved the audio to %s", *audioPath)
+	}
+
+	// refuse to overwrite anything unless forced, as there is no one to ask
+	if !*force {
+		for _, file := range outputFiles(*output, len(parts)) {
+			if _, err := os.Stat(file); err == nil {
+				fmt.Fprintf(os.Stderr, "%s already exists, use --force to overwrite it\n", file)
+				return 1
+			}
+		}
+	}
+
+	createMIDI(*output, *ppq, *bpm, parts, logf, func() {
+		logf("saved to %s", *output)
+	})
+
+	// re-read what was written to make sure it matches what was generated
+	// notes can go past the length by up to the max note length, unless they are trimmed
+	maxTick := *ticks
+	if !*trimNotes {
+		maxTick += *maxNoteLength
+	}
+	if problems := verifyOutput(*output, parts, *noteCount, uint32(maxTick)); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "verification failed: %s\n", problem)
+		}
+		return 1
+	}
+	logf("verified %d notes", *noteCount)
+	return 0
+}
+
+// Gets the Note Channel setting (see parseNoteChannel) of a channel given as a flag
+func cliNoteChannel(channel string) (string, error) {
+	switch strings.ToLower(channel) {
+	case "all":
+		return "All", nil
+	case "all-skip-drums":
+		return "All (Skip Drums)", nil
+	case "10":
+		return "10 (Drums)", nil
+	}
+
+	if number, err := strconv.Atoi(channel); err != nil || number < 1 || number > 16 {
+		return "", fmt.Errorf("%q is not a channel from 1 to 16, all, or all-skip-drums", channel)
+	}
+	return channel, nil
+}
+
+// Writes the statistics as indented json, to stdout if statsPath is "-"
+func writeStats(statsPath string, stats Stats) error {
+	encode := func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(stats)
+	}
+
+	if statsPath == "-" {
+		return encode(os.Stdout)
+	}
+	return writeFileAtomic(statsPath, encode)
+}
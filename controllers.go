@@ -0,0 +1,169 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"gitlab.com/gomidi/midi/v2"
+)
+
+// Controller lanes shown in the settings dialog
+const (
+	LaneModWheel   = "Mod Wheel"
+	LaneVolume     = "Volume"
+	LanePan        = "Pan"
+	LaneExpression = "Expression"
+	LaneSustain    = "Sustain Pedal" // on when the curve is at 64 or above, off below
+	LanePitchBend  = "Pitch Bend"    // 64 is no bend, 0 and 127 are the most bend down and up
+)
+
+var controllerLanes = []string{LaneModWheel, LaneVolume, LanePan, LaneExpression, LaneSustain, LanePitchBend}
+
+// Control change numbers of the lanes, pitch bend is its own message
+var laneControllers = map[string]uint8{
+	LaneModWheel:   1,
+	LaneVolume:     7,
+	LanePan:        10,
+	LaneExpression: 11,
+	LaneSustain:    64,
+}
+
+// Curve shapes shown in the settings dialog
+const (
+	ShapeRandom   = "Random"   // a random value at every point
+	ShapeLFO      = "LFO"      // a sine wave, repeating a number of times over the length of the midi
+	ShapeEnvelope = "Envelope" // rises from min to max over the first quarter, holds, then falls back over the last quarter
+)
+
+var controllerShapes = []string{ShapeRandom, ShapeLFO, ShapeEnvelope}
+
+// Settings for generating controller and pitch bend curves alongside the notes of each track
+type ControllerOptions struct {
+	Lanes      []string // lanes to generate, none to only generate notes
+	Shape      string
+	Resolution int // ticks between each point of the curves
+	Cycles     int // number of times the lfo repeats over the length of the midi
+	Min        int // lowest value of the curves, 0 - 127
+	Max        int // highest value of the curves, 0 - 127
+}
+
+// A controller or pitch bend message, and the tick it is sent on
+type ControllerEvent struct {
+	tick    uint32
+	message midi.Message
+}
+
+// Parses a comma separated list of lanes, leaving out anything which is not a lane
+func parseLanes(text string) []string {
+	var lanes []string
+	for _, field := range strings.Split(text, ",") {
+		field = strings.TrimSpace(field)
+		if _, ok := laneControllers[field]; ok || field == LanePitchBend {
+			lanes = append(lanes, field)
+		}
+	}
+	return lanes
+}
+
+// Creates the controller curves for every channel the notes are on which is not marked in curveChannels, in order
+// of their tick, and marks those channels, so each channel only gets its curves once over every track
+// Points which do not change the value are left out, and pitch bend and the sustain pedal are reset at the end
+func controllerEvents(notes []Note, opts GenOptions, curveChannels *[16]bool) []ControllerEvent {
+	var (
+		controllers = opts.Controllers
+		events      []ControllerEvent
+		channels    []uint8
+		resolution  = controllers.Resolution
+	)
+	if len(controllers.Lanes) == 0 || opts.Ticks < 1 {
+		return nil
+	}
+	if resolution < 1 {
+		resolution = 1
+	}
+
+	for _, note := range notes {
+		if !curveChannels[note.Channel] {
+			curveChannels[note.Channel] = true
+			channels = append(channels, note.Channel)
+		}
+	}
+
+	for _, channel := range channels {
+		for _, lane := range controllers.Lanes {
+			last := -1
+			for tick := 0; tick < opts.Ticks; tick += resolution {
+				value := controllerValue(controllers, float64(tick)/float64(opts.Ticks))
+				if lane == LaneSustain {
+					value = sustainValue(value)
+				}
+				if value == last {
+					continue
+				}
+				last = value
+
+				events = append(events, ControllerEvent{uint32(tick), laneMessage(lane, channel, value)})
+			}
+
+			// leave the channel without a bend, and the pedal up, once the midi ends
+			if lane == LanePitchBend || lane == LaneSustain {
+				events = append(events, ControllerEvent{uint32(opts.Ticks), laneMessage(lane, channel, laneRest(lane))})
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].tick < events[j].tick })
+	return events
+}
+
+// Gets the value of the curve at t, from 0 (the start of the midi) to 1 (the end)
+func controllerValue(controllers ControllerOptions, t float64) int {
+	var (
+		low    = float64(controllers.Min)
+		high   = float64(controllers.Max)
+		amount float64 // how far between low and high the value is, 0 - 1
+	)
+
+	switch controllers.Shape {
+	case ShapeLFO:
+		amount = (1 - math.Cos(2*math.Pi*float64(controllers.Cycles)*t)) / 2
+	case ShapeEnvelope:
+		amount = math.Min(1, math.Min(t*4, (1-t)*4))
+	default:
+		amount = rand.Float64()
+	}
+
+	return int(math.Round(low + (high-low)*amount))
+}
+
+// Turns a curve value into the pedal being up (0) or down (127)
+func sustainValue(value int) int {
+	if value >= 64 {
+		return 127
+	}
+	return 0
+}
+
+// Gets the value a lane is left at when the midi ends
+func laneRest(lane string) int {
+	if lane == LanePitchBend {
+		return 64
+	}
+	return 0
+}
+
+// Creates the message of a lane, with a value from 0 to 127
+func laneMessage(lane string, channel uint8, value int) midi.Message {
+	if lane != LanePitchBend {
+		return midi.ControlChange(channel, laneControllers[lane], uint8(value))
+	}
+
+	// 64 is the center, with the most bend down at 0 and up at 127
+	bend := (value - 64) * 8192 / 64
+	if value > 64 {
+		bend = (value - 64) * 8191 / 63
+	}
+	return midi.Pitchbend(channel, int16(bend))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Counts the controller and pitch bend events of a track
+func countControllers(track smf.Track) int {
+	var (
+		count                      int
+		channel, controller, value uint8
+		relative                   int16
+		absolute                   uint16
+	)
+	for _, event := range track {
+		if event.Message.GetControlChange(&channel, &controller, &value) || event.Message.GetPitchBend(&channel, &relative, &absolute) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestArrangeTracksControllers(t *testing.T) {
+	opts := GenOptions{
+		Ticks:       960,
+		Controllers: ControllerOptions{Lanes: []string{LaneVolume, LanePitchBend}, Shape: ShapeLFO, Resolution: 96, Cycles: 1, Min: 0, Max: 127},
+	}
+	trackNotes := [][]Note{
+		{{Channel: 0, Key: 60, Velocity: 100, Start: 0, End: 10}},
+		{{Channel: 0, Key: 61, Velocity: 100, Start: 0, End: 10}},
+		{{Channel: 1, Key: 62, Velocity: 100, Start: 0, End: 10}},
+	}
+
+	tracks, err := arrangeTracks(trackNotes, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the first track on each channel gets the curves
+	got := []int{countControllers(tracks[0]), countControllers(tracks[1]), countControllers(tracks[2])}
+	if got[0] == 0 || got[1] != 0 || got[2] != got[0] {
+		t.Errorf("got %v controller events in each track, want curves in the first and last track only", got)
+	}
+}
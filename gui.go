@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"image"
 	"image/color"
+	"io"
 	"net/url"
+	"os"
 	"path"
 	"strconv"
 	"strings"
@@ -18,6 +22,7 @@ import (
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"gitlab.com/gomidi/midi/v2/smf"
 )
 
 func createGUI() {
@@ -73,6 +78,9 @@ func createGUI() {
 			// max notes per track
 			MaxNotesNumInput := createNumberInput(0, -1)
 
+			// most notes held at once in a track, 0 for no limit
+			MaxPolyphonyNumInput := createNumberInput(0, -1)
+
 			// length type
 			// ticks, seconds, bars
 			LengthSelectInput := widget.NewSelect([]string{"MIDI Ticks", "MIDI Bars"}, func(string) {})
@@ -80,6 +88,15 @@ func createGUI() {
 			// whether to cut off notes that are longer than the length of the midi
 			TrimNotesChkInput := widget.NewCheck("Cut Notes", func(bool) {})
 
+			// how note lengths are picked between the min and max note length
+			// musical lengths can also be dotted or triplets
+			LengthDistributionSelectInput := widget.NewSelect(lengthDistributions, func(string) {})
+			DottedChkInput := widget.NewCheck("Dotted", func(bool) {})
+			TripletsChkInput := widget.NewCheck("Triplets", func(bool) {})
+
+			// length of notes as a percent of the gap to the next note, 0 to use the note lengths above
+			GateNumInput := createNumberInput(0, 100)
+
 			// note velocity
 			// both min and max
 			MinVelocityNumInput := createNumberInput(1, 127)
@@ -88,37 +105,391 @@ func createGUI() {
 			// Channel to use from 1 - 16
 			ChannelSelectInput := widget.NewSelect([]string{"All (Skip Drums)", "All", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10 (Drums)", "11", "12", "13", "14", "15", "16"}, func(string) {})
 
-			// turn into form FormItems
-			FormItems := []*widget.FormItem{
+			// how channels are given to tracks or notes, instead of only the note channel above
+			// the channels are a list like "1,2,5", with weights like "1:3, 2:1", or key ranges like "0-59:1, 60-127:2"
+			ChannelStrategySelectInput := widget.NewSelect(channelStrategies, func(string) {})
+			ChannelListTxtInput := widget.NewEntry()
+			ChannelListTxtInput.SetPlaceHolder("1,2,5 | 1:3, 2:1 | 0-59:1, 60-127:2")
+			ChannelListTxtInput.Validator = func(input string) error {
+				_, err := parseChannelStrategy(ChannelStrategySelectInput.Selected, input)
+				return err
+			}
+
+			// how the notes are split into tracks, either filling each track up to the max notes per track,
+			// evenly, into a number of tracks, or into a part of the midi for each track
+			TrackSplitSelectInput := widget.NewSelect(trackSplits, func(string) {})
+			TrackCountNumInput := createNumberInput(1, -1)
+			TrackKeysNumInput := createNumberInput(1, 128)
+
+			// splitting the output into multiple files
+			// limit is in tracks, notes, or megabytes depending on the mode
+			SplitSelectInput := widget.NewSelect(splitModes, func(string) {})
+			SplitLimitNumInput := createNumberInput(1, -1)
+
+			// exporting every note to a csv or json lines file, alongside or instead of the midi
+			NoteListSelectInput := widget.NewSelect(noteListFormats, func(string) {})
+			NoteListOnlyChkInput := widget.NewCheck("Skip MIDI", func(bool) {})
+
+			// whether imported notes keep the tracks and channels from the note list
+			ImportKeepTracksChkInput := widget.NewCheck("Keep Tracks", func(bool) {})
+			ImportKeepChannelsChkInput := widget.NewCheck("Keep Channels", func(bool) {})
+			// colors for Black MIDI players, by track or channel, with the tracks or channels ordered to follow them
+			// the palette can also be saved alongside the midi
+			PaletteSelectInput := widget.NewSelect(paletteNames, func(string) {})
+			PaletteColorsTxtInput := widget.NewEntry()
+			PaletteColorsTxtInput.SetPlaceHolder("#ff3333, #33ff80, #3399ff")
+			PaletteColorsTxtInput.Validator = func(input string) error {
+				_, err := paletteColors(PaletteSelectInput.Selected, input)
+				return err
+			}
+			PaletteColorBySelectInput := widget.NewSelect([]string{"Track", "Channel"}, func(string) {})
+			ColorOrderSelectInput := widget.NewSelect(colorOrders, func(string) {})
+			PaletteFileSelectInput := widget.NewSelect(paletteFileFormats, func(string) {})
+
+			// what the notes are generated from
+			ModeSelectInput := widget.NewSelect(generationModes, func(string) {})
+
+			// image mode
+			// image to draw, the keys it covers, how many time slices it is split into,
+			// and how much ink a pixel needs before it becomes a note
+			ImagePathTxtInput := widget.NewEntry()
+			ImagePathTxtInput.SetPlaceHolder("picture.png")
+			ImageBrowseBTN := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+				fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, _ error) {
+					if reader == nil {
+						return
+					}
+					defer reader.Close()
+					ImagePathTxtInput.SetText(reader.URI().Path())
+				}, window)
+				fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".jpg", ".jpeg"}))
+				fileDialog.Show()
+			})
+			ImageMinKeyNumInput := createNumberInput(0, 127)
+			ImageMaxKeyNumInput := createNumberInput(0, 127)
+			ImageColumnsNumInput := createNumberInput(0, -1)
+			ImageThresholdNumInput := createNumberInput(0, 100)
+
+			// text mode
+			// text to spell, the keys it covers, and whether it reads along time or across the keys
+			TextTxtInput := widget.NewEntry()
+			TextTxtInput.SetPlaceHolder("Hello World")
+			TextMinKeyNumInput := createNumberInput(0, 127)
+			TextMaxKeyNumInput := createNumberInput(0, 127)
+			TextDirectionSelectInput := widget.NewSelect(textDirections, func(string) {})
+
+			// patterns
+			// which pattern to place notes in, how often it repeats, how many keys it covers,
+			// how many notes are stacked at each step, and the percent of notes placed in the pattern
+			PatternSelectInput := widget.NewSelect(patterns, func(string) {})
+			PatternCyclesNumInput := createNumberInput(1, -1)
+			PatternWidthNumInput := createNumberInput(1, 128)
+			PatternDensityNumInput := createNumberInput(1, 128)
+			PatternMixNumInput := createNumberInput(0, 100)
+
+			// random walk mode
+			// keys the walk stays between, the largest step it can take and how likely each step size is,
+			// and the scale it snaps to
+			WalkMinKeyNumInput := createNumberInput(0, 127)
+			WalkMaxKeyNumInput := createNumberInput(0, 127)
+			WalkMaxStepNumInput := createNumberInput(1, 127)
+			WalkDistributionSelectInput := widget.NewSelect(stepDistributions, func(string) {})
+			WalkScaleSelectInput := widget.NewSelect(scaleNames, func(string) {})
+			WalkRootSelectInput := widget.NewSelect(keyNames, func(string) {})
+
+			// markov chain mode
+			// midi to learn from, or a model saved as json, how many previous keys are used to pick the next,
+			// and how closely the model is followed
+			MarkovPathTxtInput := widget.NewEntry()
+			MarkovPathTxtInput.SetPlaceHolder("song.mid or model.json")
+			MarkovBrowseBTN := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+				fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, _ error) {
+					if reader == nil {
+						return
+					}
+					defer reader.Close()
+					MarkovPathTxtInput.SetText(reader.URI().Path())
+				}, window)
+				fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".mid", ".midi", ".json"}))
+				fileDialog.Show()
+			})
+			MarkovOrderNumInput := createNumberInput(1, 8)
+			MarkovTemperatureNumInput := createNumberInput(0, 1000)
+
+			// rhythm
+			// notes start on the hits of a euclidean rhythm, or of step patterns, instead of on any tick
+			RhythmSelectInput := widget.NewSelect(rhythms, func(string) {})
+			RhythmPulsesNumInput := createNumberInput(1, 64)
+			RhythmStepsNumInput := createNumberInput(1, 64)
+			RhythmPatternTxtInput := widget.NewEntry()
+			RhythmPatternTxtInput.SetPlaceHolder("x..x..x. | x.x.x.x.")
+
+			// chords
+			// notes are generated as chords of a type, or random clusters, with a number of notes in each
+			// and the most semitones between the lowest and highest note
+			ChordSelectInput := widget.NewSelect(chords, func(string) {})
+			ChordSizeNumInput := createNumberInput(1, 128)
+			ChordSpreadNumInput := createNumberInput(0, 127)
+
+			// controllers
+			// curves for controllers and pitch bend, added to every track for each channel it uses
+			// the curves have a point every resolution ticks, with values from min to max
+			ControllerLanesChkInput := widget.NewCheckGroup(controllerLanes, func([]string) {})
+			ControllerShapeSelectInput := widget.NewSelect(controllerShapes, func(string) {})
+			ControllerResolutionNumInput := createNumberInput(1, -1)
+			ControllerCyclesNumInput := createNumberInput(1, -1)
+			ControllerMinNumInput := createNumberInput(0, 127)
+			ControllerMaxNumInput := createNumberInput(0, 127)
+
+			// turn into forms, one for each tab
+			NotesForm := widget.NewForm(
+				widget.NewFormItem("Generation Mode", ModeSelectInput),
 				widget.NewFormItem("Max Notes Per Track", MaxNotesNumInput),
+				widget.NewFormItem("Track Split", TrackSplitSelectInput),
+				widget.NewFormItem("Track Count", TrackCountNumInput),
+				widget.NewFormItem("Keys Per Track", TrackKeysNumInput),
+				widget.NewFormItem("Max Polyphony", MaxPolyphonyNumInput),
 				widget.NewFormItem("Length Type", LengthSelectInput),
 				widget.NewFormItem("Trim Notes", TrimNotesChkInput),
+				widget.NewFormItem("Note Lengths", LengthDistributionSelectInput),
+				widget.NewFormItem("Musical Lengths", container.NewHBox(DottedChkInput, TripletsChkInput)),
+				widget.NewFormItem("Gate %", GateNumInput),
 				widget.NewFormItem("Min Note Velocity", MinVelocityNumInput),
 				widget.NewFormItem("MaxNote Velocity", MaxVelocityNumInput),
 				widget.NewFormItem("Note Channel", ChannelSelectInput),
-			}
+				widget.NewFormItem("Channel Strategy", ChannelStrategySelectInput),
+				widget.NewFormItem("Channels", ChannelListTxtInput),
+			)
+			OutputForm := widget.NewForm(
+				widget.NewFormItem("Split Output", SplitSelectInput),
+				widget.NewFormItem("Split Limit", SplitLimitNumInput),
+				widget.NewFormItem("Note List", NoteListSelectInput),
+				widget.NewFormItem("Note List Only", NoteListOnlyChkInput),
+				widget.NewFormItem("Imported Notes", container.NewHBox(ImportKeepTracksChkInput, ImportKeepChannelsChkInput)),
+				widget.NewFormItem("Palette", PaletteSelectInput),
+				widget.NewFormItem("Custom Colors", PaletteColorsTxtInput),
+				widget.NewFormItem("Color By", PaletteColorBySelectInput),
+				widget.NewFormItem("Color Order", ColorOrderSelectInput),
+				widget.NewFormItem("Palette File", PaletteFileSelectInput),
+			)
+			ImageForm := widget.NewForm(
+				widget.NewFormItem("Image", container.NewBorder(nil, nil, nil, ImageBrowseBTN, ImagePathTxtInput)),
+				widget.NewFormItem("Lowest Key", ImageMinKeyNumInput),
+				widget.NewFormItem("Highest Key", ImageMaxKeyNumInput),
+				widget.NewFormItem("Columns", ImageColumnsNumInput),
+				widget.NewFormItem("Threshold %", ImageThresholdNumInput),
+			)
+			TextForm := widget.NewForm(
+				widget.NewFormItem("Text", TextTxtInput),
+				widget.NewFormItem("Lowest Key", TextMinKeyNumInput),
+				widget.NewFormItem("Highest Key", TextMaxKeyNumInput),
+				widget.NewFormItem("Direction", TextDirectionSelectInput),
+			)
+			PatternForm := widget.NewForm(
+				widget.NewFormItem("Pattern", PatternSelectInput),
+				widget.NewFormItem("Cycles", PatternCyclesNumInput),
+				widget.NewFormItem("Width (Keys)", PatternWidthNumInput),
+				widget.NewFormItem("Density", PatternDensityNumInput),
+				widget.NewFormItem("Mix %", PatternMixNumInput),
+			)
+			WalkForm := widget.NewForm(
+				widget.NewFormItem("Lowest Key", WalkMinKeyNumInput),
+				widget.NewFormItem("Highest Key", WalkMaxKeyNumInput),
+				widget.NewFormItem("Max Step", WalkMaxStepNumInput),
+				widget.NewFormItem("Step Sizes", WalkDistributionSelectInput),
+				widget.NewFormItem("Scale", WalkScaleSelectInput),
+				widget.NewFormItem("Root", WalkRootSelectInput),
+			)
+			MarkovForm := widget.NewForm(
+				widget.NewFormItem("Model", container.NewBorder(nil, nil, nil, MarkovBrowseBTN, MarkovPathTxtInput)),
+				widget.NewFormItem("Order", MarkovOrderNumInput),
+				widget.NewFormItem("Temperature %", MarkovTemperatureNumInput),
+			)
+			RhythmForm := widget.NewForm(
+				widget.NewFormItem("Rhythm", RhythmSelectInput),
+				widget.NewFormItem("Pulses", RhythmPulsesNumInput),
+				widget.NewFormItem("Steps", RhythmStepsNumInput),
+				widget.NewFormItem("Step Pattern", RhythmPatternTxtInput),
+			)
+			ChordForm := widget.NewForm(
+				widget.NewFormItem("Chord", ChordSelectInput),
+				widget.NewFormItem("Notes Per Chord", ChordSizeNumInput),
+				widget.NewFormItem("Spread (Keys)", ChordSpreadNumInput),
+			)
+			ControllerForm := widget.NewForm(
+				widget.NewFormItem("Lanes", ControllerLanesChkInput),
+				widget.NewFormItem("Shape", ControllerShapeSelectInput),
+				widget.NewFormItem("Resolution (Ticks)", ControllerResolutionNumInput),
+				widget.NewFormItem("LFO Cycles", ControllerCyclesNumInput),
+				widget.NewFormItem("Min Value", ControllerMinNumInput),
+				widget.NewFormItem("Max Value", ControllerMaxNumInput),
+			)
+
+			SettingsTabs := container.NewAppTabs(
+				container.NewTabItem("Notes", NotesForm),
+				container.NewTabItem("Output", OutputForm),
+				container.NewTabItem("Image", ImageForm),
+				container.NewTabItem("Text", TextForm),
+				container.NewTabItem("Pattern", PatternForm),
+				container.NewTabItem("Walk", WalkForm),
+				container.NewTabItem("Markov", MarkovForm),
+				container.NewTabItem("Rhythm", RhythmForm),
+				container.NewTabItem("Chords", ChordForm),
+				container.NewTabItem("Controllers", ControllerForm),
+			)
 
 			// set default values
+			ModeSelectInput.SetSelected(app.Preferences().StringWithFallback("generationMode", ModeRandom))
 			MaxNotesNumInput.SetText(app.Preferences().StringWithFallback("maxNotesPerTrack", "1000"))
+			TrackSplitSelectInput.SetSelected(app.Preferences().StringWithFallback("trackSplit", TrackSplitFill))
+			TrackCountNumInput.SetText(app.Preferences().StringWithFallback("trackCount", "16"))
+			TrackKeysNumInput.SetText(app.Preferences().StringWithFallback("trackKeys", "12"))
+			MaxPolyphonyNumInput.SetText(app.Preferences().StringWithFallback("maxPolyphony", "0"))
 			LengthSelectInput.SetSelected(app.Preferences().StringWithFallback("lengthType", "MIDI Ticks"))
 			TrimNotesChkInput.SetChecked(app.Preferences().BoolWithFallback("trimNotes", true))
+			LengthDistributionSelectInput.SetSelected(app.Preferences().StringWithFallback("lengthDistribution", LengthUniform))
+			DottedChkInput.SetChecked(app.Preferences().BoolWithFallback("dottedLengths", false))
+			TripletsChkInput.SetChecked(app.Preferences().BoolWithFallback("tripletLengths", false))
+			GateNumInput.SetText(app.Preferences().StringWithFallback("gate", "0"))
 			MinVelocityNumInput.SetText(app.Preferences().StringWithFallback("minNoteVelocity", "50"))
 			MaxVelocityNumInput.SetText(app.Preferences().StringWithFallback("maxNoteVelocity", "100"))
 			ChannelSelectInput.SetSelected(app.Preferences().StringWithFallback("noteChannel", "16"))
+			ChannelStrategySelectInput.SetSelected(app.Preferences().StringWithFallback("channelStrategy", ChannelFromSetting))
+			ChannelListTxtInput.SetText(app.Preferences().StringWithFallback("channelList", ""))
+			SplitSelectInput.SetSelected(app.Preferences().StringWithFallback("splitMode", SplitNone))
+			SplitLimitNumInput.SetText(app.Preferences().StringWithFallback("splitLimit", "1000"))
+			NoteListSelectInput.SetSelected(app.Preferences().StringWithFallback("noteList", NoteListNone))
+			NoteListOnlyChkInput.SetChecked(app.Preferences().BoolWithFallback("noteListOnly", false))
+			ImportKeepTracksChkInput.SetChecked(app.Preferences().BoolWithFallback("importKeepTracks", false))
+			ImportKeepChannelsChkInput.SetChecked(app.Preferences().BoolWithFallback("importKeepChannels", false))
+			PaletteSelectInput.SetSelected(app.Preferences().StringWithFallback("palette", PaletteRainbow))
+			PaletteColorsTxtInput.SetText(app.Preferences().StringWithFallback("paletteColors", formatColors(defaultColors)))
+			PaletteColorBySelectInput.SetSelected(app.Preferences().StringWithFallback("paletteColorBy", "Track"))
+			ColorOrderSelectInput.SetSelected(app.Preferences().StringWithFallback("colorOrder", ColorOrderGenerated))
+			PaletteFileSelectInput.SetSelected(app.Preferences().StringWithFallback("paletteFile", PaletteFileNone))
+			ImagePathTxtInput.SetText(app.Preferences().StringWithFallback("imagePath", ""))
+			ImageMinKeyNumInput.SetText(app.Preferences().StringWithFallback("imageModeMinKey", "0"))
+			ImageMaxKeyNumInput.SetText(app.Preferences().StringWithFallback("imageModeMaxKey", "127"))
+			ImageColumnsNumInput.SetText(app.Preferences().StringWithFallback("imageModeColumns", "0"))
+			ImageThresholdNumInput.SetText(app.Preferences().StringWithFallback("imageModeThreshold", "50"))
+			TextTxtInput.SetText(app.Preferences().StringWithFallback("text", ""))
+			TextMinKeyNumInput.SetText(app.Preferences().StringWithFallback("textMinKey", "36"))
+			TextMaxKeyNumInput.SetText(app.Preferences().StringWithFallback("textMaxKey", "91"))
+			TextDirectionSelectInput.SetSelected(app.Preferences().StringWithFallback("textDirection", TextScrolling))
+			PatternSelectInput.SetSelected(app.Preferences().StringWithFallback("pattern", PatternNone))
+			PatternCyclesNumInput.SetText(app.Preferences().StringWithFallback("patternCycles", "4"))
+			PatternWidthNumInput.SetText(app.Preferences().StringWithFallback("patternWidth", "64"))
+			PatternDensityNumInput.SetText(app.Preferences().StringWithFallback("patternDensity", "1"))
+			PatternMixNumInput.SetText(app.Preferences().StringWithFallback("patternMix", "100"))
+			WalkMinKeyNumInput.SetText(app.Preferences().StringWithFallback("walkMinKey", "36"))
+			WalkMaxKeyNumInput.SetText(app.Preferences().StringWithFallback("walkMaxKey", "96"))
+			WalkMaxStepNumInput.SetText(app.Preferences().StringWithFallback("walkMaxStep", "3"))
+			WalkDistributionSelectInput.SetSelected(app.Preferences().StringWithFallback("walkDistribution", StepUniform))
+			WalkScaleSelectInput.SetSelected(app.Preferences().StringWithFallback("walkScale", "None"))
+			WalkRootSelectInput.SetSelected(app.Preferences().StringWithFallback("walkRoot", "C"))
+			MarkovPathTxtInput.SetText(app.Preferences().StringWithFallback("markovPath", ""))
+			MarkovOrderNumInput.SetText(app.Preferences().StringWithFallback("markovOrder", "2"))
+			MarkovTemperatureNumInput.SetText(app.Preferences().StringWithFallback("markovTemperature", "100"))
+			RhythmSelectInput.SetSelected(app.Preferences().StringWithFallback("rhythm", RhythmNone))
+			RhythmPulsesNumInput.SetText(app.Preferences().StringWithFallback("rhythmPulses", "3"))
+			RhythmStepsNumInput.SetText(app.Preferences().StringWithFallback("rhythmSteps", "8"))
+			RhythmPatternTxtInput.SetText(app.Preferences().StringWithFallback("rhythmPattern", "x..x..x."))
+			ChordSelectInput.SetSelected(app.Preferences().StringWithFallback("chord", ChordNone))
+			ChordSizeNumInput.SetText(app.Preferences().StringWithFallback("chordSize", "3"))
+			ChordSpreadNumInput.SetText(app.Preferences().StringWithFallback("chordSpread", "12"))
+			ControllerLanesChkInput.SetSelected(parseLanes(app.Preferences().StringWithFallback("controllerLanes", "")))
+			ControllerShapeSelectInput.SetSelected(app.Preferences().StringWithFallback("controllerShape", ShapeLFO))
+			ControllerResolutionNumInput.SetText(app.Preferences().StringWithFallback("controllerResolution", "24"))
+			ControllerCyclesNumInput.SetText(app.Preferences().StringWithFallback("controllerCycles", "4"))
+			ControllerMinNumInput.SetText(app.Preferences().StringWithFallback("controllerMin", "0"))
+			ControllerMaxNumInput.SetText(app.Preferences().StringWithFallback("controllerMax", "127"))
 
-			dialog.ShowForm("Settings", "Save", "Cancel", FormItems, func(b bool) {
+			var settingsDialog dialog.Dialog
+			settingsDialog = dialog.NewCustomConfirm("Settings", "Save", "Cancel", SettingsTabs, func(b bool) {
 				if !b {
 					return
 				}
 
+				// refuse to save while any input is invalid, showing the settings again so they can be fixed
+				var problems []string
+				for _, tab := range SettingsTabs.Items {
+					for _, problem := range validateForm(tab.Content.(*widget.Form)) {
+						problems = append(problems, strings.ToLower(tab.Text)+": "+problem)
+					}
+				}
+				if len(problems) > 0 {
+					settingsDialog.Show()
+					dialog.ShowInformation("Invalid Options", strings.Join(problems, "\n"), window)
+					return
+				}
+
 				// save values
+				app.Preferences().SetString("generationMode", ModeSelectInput.Selected)
 				app.Preferences().SetString("maxNotesPerTrack", MaxNotesNumInput.Text)
+				app.Preferences().SetString("trackSplit", TrackSplitSelectInput.Selected)
+				app.Preferences().SetString("trackCount", TrackCountNumInput.Text)
+				app.Preferences().SetString("trackKeys", TrackKeysNumInput.Text)
+				app.Preferences().SetString("maxPolyphony", MaxPolyphonyNumInput.Text)
 				app.Preferences().SetString("lengthType", LengthSelectInput.Selected)
 				app.Preferences().SetBool("trimNotes", TrimNotesChkInput.Checked)
+				app.Preferences().SetString("lengthDistribution", LengthDistributionSelectInput.Selected)
+				app.Preferences().SetBool("dottedLengths", DottedChkInput.Checked)
+				app.Preferences().SetBool("tripletLengths", TripletsChkInput.Checked)
+				app.Preferences().SetString("gate", GateNumInput.Text)
 				app.Preferences().SetString("minNoteVelocity", MinVelocityNumInput.Text)
 				app.Preferences().SetString("maxNoteVelocity", MaxVelocityNumInput.Text)
 				app.Preferences().SetString("noteChannel", ChannelSelectInput.Selected)
+				app.Preferences().SetString("channelStrategy", ChannelStrategySelectInput.Selected)
+				app.Preferences().SetString("channelList", ChannelListTxtInput.Text)
+				app.Preferences().SetString("splitMode", SplitSelectInput.Selected)
+				app.Preferences().SetString("splitLimit", SplitLimitNumInput.Text)
+				app.Preferences().SetString("noteList", NoteListSelectInput.Selected)
+				app.Preferences().SetBool("noteListOnly", NoteListOnlyChkInput.Checked)
+				app.Preferences().SetBool("importKeepTracks", ImportKeepTracksChkInput.Checked)
+				app.Preferences().SetBool("importKeepChannels", ImportKeepChannelsChkInput.Checked)
+				app.Preferences().SetString("palette", PaletteSelectInput.Selected)
+				app.Preferences().SetString("paletteColors", PaletteColorsTxtInput.Text)
+				app.Preferences().SetString("paletteColorBy", PaletteColorBySelectInput.Selected)
+				app.Preferences().SetString("colorOrder", ColorOrderSelectInput.Selected)
+				app.Preferences().SetString("paletteFile", PaletteFileSelectInput.Selected)
+				app.Preferences().SetString("imagePath", ImagePathTxtInput.Text)
+				app.Preferences().SetString("imageModeMinKey", ImageMinKeyNumInput.Text)
+				app.Preferences().SetString("imageModeMaxKey", ImageMaxKeyNumInput.Text)
+				app.Preferences().SetString("imageModeColumns", ImageColumnsNumInput.Text)
+				app.Preferences().SetString("imageModeThreshold", ImageThresholdNumInput.Text)
+				app.Preferences().SetString("text", TextTxtInput.Text)
+				app.Preferences().SetString("textMinKey", TextMinKeyNumInput.Text)
+				app.Preferences().SetString("textMaxKey", TextMaxKeyNumInput.Text)
+				app.Preferences().SetString("textDirection", TextDirectionSelectInput.Selected)
+				app.Preferences().SetString("pattern", PatternSelectInput.Selected)
+				app.Preferences().SetString("patternCycles", PatternCyclesNumInput.Text)
+				app.Preferences().SetString("patternWidth", PatternWidthNumInput.Text)
+				app.Preferences().SetString("patternDensity", PatternDensityNumInput.Text)
+				app.Preferences().SetString("patternMix", PatternMixNumInput.Text)
+				app.Preferences().SetString("walkMinKey", WalkMinKeyNumInput.Text)
+				app.Preferences().SetString("walkMaxKey", WalkMaxKeyNumInput.Text)
+				app.Preferences().SetString("walkMaxStep", WalkMaxStepNumInput.Text)
+				app.Preferences().SetString("walkDistribution", WalkDistributionSelectInput.Selected)
+				app.Preferences().SetString("walkScale", WalkScaleSelectInput.Selected)
+				app.Preferences().SetString("walkRoot", WalkRootSelectInput.Selected)
+				app.Preferences().SetString("markovPath", MarkovPathTxtInput.Text)
+				app.Preferences().SetString("markovOrder", MarkovOrderNumInput.Text)
+				app.Preferences().SetString("markovTemperature", MarkovTemperatureNumInput.Text)
+				app.Preferences().SetString("rhythm", RhythmSelectInput.Selected)
+				app.Preferences().SetString("rhythmPulses", RhythmPulsesNumInput.Text)
+				app.Preferences().SetString("rhythmSteps", RhythmStepsNumInput.Text)
+				app.Preferences().SetString("rhythmPattern", RhythmPatternTxtInput.Text)
+				app.Preferences().SetString("chord", ChordSelectInput.Selected)
+				app.Preferences().SetString("chordSize", ChordSizeNumInput.Text)
+				app.Preferences().SetString("chordSpread", ChordSpreadNumInput.Text)
+				app.Preferences().SetString("controllerLanes", strings.Join(ControllerLanesChkInput.Selected, ","))
+				app.Preferences().SetString("controllerShape", ControllerShapeSelectInput.Selected)
+				app.Preferences().SetString("controllerResolution", ControllerResolutionNumInput.Text)
+				app.Preferences().SetString("controllerCycles", ControllerCyclesNumInput.Text)
+				app.Preferences().SetString("controllerMin", ControllerMinNumInput.Text)
+				app.Preferences().SetString("controllerMax", ControllerMaxNumInput.Text)
 			}, window)
+			settingsDialog.Show()
 		}),
 	)
 
@@ -172,8 +543,329 @@ func createGUI() {
 	OutputLogTxt := widget.NewMultiLineEntry()
 	OutputLogTxt.SetText("Output will go here...")
 
-	// create button
-	CreateBTN := widget.NewButton("Create", func() {
+	// appends a formatted line to the output box
+	logOutput := func(format string, args ...any) {
+		OutputLogTxt.SetText(OutputLogTxt.Text + fmt.Sprintf(format, args...) + "\n")
+	}
+
+	// statistics box
+	// shows statistics about the last created, or analyzed, midi
+	StatsTxt := widget.NewMultiLineEntry()
+	StatsTxt.TextStyle = fyne.TextStyle{Monospace: true}
+	StatsTxt.SetText("Statistics will go here...")
+
+	var lastStats *Stats
+	showStats := func(stats Stats) {
+		lastStats = &stats
+		StatsTxt.SetText(formatStats(stats))
+	}
+
+	// analyze button
+	// user can select any midi to show statistics for
+	AnalyzeMIDIBTN := widget.NewButtonWithIcon("Analyze MIDI", theme.FolderOpenIcon(), func() {
+		fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, _ error) {
+			if reader == nil { // if the user did not select a file
+				return
+			}
+			defer reader.Close()
+
+			midiData, err := smf.ReadFrom(reader)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+
+			stats, err := analyzeMIDI(midiData)
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+			showStats(stats)
+		}, window)
+
+		fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".mid", ".midi"}))
+		fileDialog.Show()
+	})
+
+	// save statistics button
+	// saves the statistics shown as json
+	SaveStatsBTN := widget.NewButtonWithIcon("Save JSON", theme.DocumentSaveIcon(), func() {
+		if lastStats == nil {
+			dialog.ShowInformation("No Statistics", "Create or analyze a midi first", window)
+			return
+		}
+
+		fileDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, _ error) {
+			if writer == nil { // if the user did not select a file
+				return
+			}
+			defer writer.Close()
+
+			encoder := json.NewEncoder(writer)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(lastStats); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}, window)
+
+		fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+		fileDialog.SetFileName("stats.json")
+		fileDialog.Show()
+	})
+
+	// the last generated tracks, along with the values needed to save them
+	type generation struct {
+		tracks    []smf.Track
+		noteCount int
+		ppq       int
+		bpm       int
+		maxTick   int // the last tick a note can end on
+		split     SplitPolicy
+		notes     []Note
+		noteList  string // note list format, or NoteListNone
+		listOnly  bool   // only save the note list, not the midi
+		palette   PaletteOptions
+	}
+	var lastGeneration *generation
+
+	// preview box
+	// shows the last generated tracks as a piano roll, which can be zoomed in horizontally
+	var (
+		previewNotes   []Note
+		previewPalette PaletteOptions
+	)
+	PreviewImg := canvas.NewImageFromImage(nil)
+	PreviewImg.FillMode = canvas.ImageFillStretch
+	PreviewImg.ScaleMode = canvas.ImageScalePixels
+	PreviewScroll := container.NewScroll(PreviewImg)
+
+	PreviewZoomSlider := widget.NewSlider(1, 32)
+	renderPreview := func() {
+		size := fyne.NewSize(float32(PreviewScroll.Size().Width)*float32(PreviewZoomSlider.Value), PreviewScroll.Size().Height)
+		if size.Width < 1 || size.Height < 1 {
+			size = fyne.NewSize(800*float32(PreviewZoomSlider.Value), 400)
+		}
+
+		PreviewImg.Image = drawPianoRoll(previewNotes, previewPalette.renderOptions(int(size.Width), int(size.Height)))
+		PreviewImg.SetMinSize(size)
+		PreviewImg.Refresh()
+	}
+	PreviewZoomSlider.OnChanged = func(float64) { renderPreview() }
+
+	// disables all inputs while generating and saving
+	disableInputs := func() {
+		OutputPathTxtInput.Disable()
+		TicksNumInput.Disable()
+		MinNoteLenNumInput.Disable()
+		MaxNoteLenNuminput.Disable()
+		PPQSelectInput.Disable()
+		BPMNumInput.Disable()
+		window.SetTitle("Random Note Generator (Running...)")
+		// TODO: add a cancel button
+		// TODO: disable the create button
+	}
+
+	// enables all inputs again, once generating or saving is done
+	enableInputs := func() {
+		OutputPathTxtInput.Enable()
+		TicksNumInput.Enable()
+		MinNoteLenNumInput.Enable()
+		MaxNoteLenNuminput.Enable()
+		PPQSelectInput.Enable()
+		BPMNumInput.Enable()
+		window.SetTitle("Random Note Generator")
+	}
+
+	// gets the split policy from the settings
+	readSplitPolicy := func() SplitPolicy {
+		splitLimit, err := strconv.Atoi(app.Preferences().StringWithFallback("splitLimit", "1000"))
+		handleErr(err)
+		return SplitPolicy{
+			Mode:  app.Preferences().StringWithFallback("splitMode", SplitNone),
+			Limit: splitLimit,
+		}
+	}
+
+	// gets the palette options from the settings
+	readPaletteOptions := func() (PaletteOptions, error) {
+		colors, err := paletteColors(
+			app.Preferences().StringWithFallback("palette", PaletteRainbow),
+			app.Preferences().StringWithFallback("paletteColors", formatColors(defaultColors)),
+		)
+		return PaletteOptions{
+			Colors:    colors,
+			ByChannel: app.Preferences().StringWithFallback("paletteColorBy", "Track") == "Channel",
+			Order:     app.Preferences().StringWithFallback("colorOrder", ColorOrderGenerated),
+			File:      app.Preferences().StringWithFallback("paletteFile", PaletteFileNone),
+		}, err
+	}
+
+	// shows statistics and a preview for the created tracks, and keeps them so they can be saved
+	// the tracks, or channels, are ordered first so their colors follow the palette
+	finishGeneration := func(tracks []smf.Track, noteCount int, ppq int, bpm int, maxTick int, split SplitPolicy, palette PaletteOptions) *generation {
+		if palette.Order != ColorOrderGenerated {
+			logOutput("color order: %s | by channel: %t", palette.Order, palette.ByChannel)
+		}
+		tracks = orderTracks(tracks, palette)
+
+		midiData := buildMIDI(ppq, bpm, tracks)
+		stats, err := analyzeMIDI(midiData)
+		handleErr(err)
+		showStats(stats)
+
+		notes := collectNotes(midiData)
+		previewNotes = notes
+		previewPalette = palette
+		renderPreview()
+
+		lastGeneration = &generation{
+			tracks:    tracks,
+			noteCount: noteCount,
+			ppq:       ppq,
+			bpm:       bpm,
+			maxTick:   maxTick,
+			split:     split,
+			notes:     notes,
+			noteList:  app.Preferences().StringWithFallback("noteList", NoteListNone),
+			listOnly:  app.Preferences().BoolWithFallback("noteListOnly", false),
+			palette:   palette,
+		}
+		return lastGeneration
+	}
+
+	// gets the image mode options from the settings
+	readImageOptions := func() ImageOptions {
+		minKey, err := strconv.Atoi(app.Preferences().StringWithFallback("imageModeMinKey", "0"))
+		handleErr(err)
+		maxKey, err := strconv.Atoi(app.Preferences().StringWithFallback("imageModeMaxKey", "127"))
+		handleErr(err)
+		columns, err := strconv.Atoi(app.Preferences().StringWithFallback("imageModeColumns", "0"))
+		handleErr(err)
+		threshold, err := strconv.Atoi(app.Preferences().StringWithFallback("imageModeThreshold", "50"))
+		handleErr(err)
+
+		return ImageOptions{
+			MinKey:    uint8(minKey),
+			MaxKey:    uint8(maxKey),
+			Columns:   columns,
+			Threshold: float64(threshold) / 100,
+		}
+	}
+
+	// gets the text mode options from the settings
+	readTextOptions := func() TextOptions {
+		minKey, err := strconv.Atoi(app.Preferences().StringWithFallback("textMinKey", "36"))
+		handleErr(err)
+		maxKey, err := strconv.Atoi(app.Preferences().StringWithFallback("textMaxKey", "91"))
+		handleErr(err)
+
+		return TextOptions{
+			Text:      app.Preferences().StringWithFallback("text", ""),
+			MinKey:    uint8(minKey),
+			MaxKey:    uint8(maxKey),
+			Direction: app.Preferences().StringWithFallback("textDirection", TextScrolling),
+		}
+	}
+
+	// gets the pattern options from the settings
+	readPatternOptions := func() PatternOptions {
+		cycles, err := strconv.Atoi(app.Preferences().StringWithFallback("patternCycles", "4"))
+		handleErr(err)
+		width, err := strconv.Atoi(app.Preferences().StringWithFallback("patternWidth", "64"))
+		handleErr(err)
+		density, err := strconv.Atoi(app.Preferences().StringWithFallback("patternDensity", "1"))
+		handleErr(err)
+		mix, err := strconv.Atoi(app.Preferences().StringWithFallback("patternMix", "100"))
+		handleErr(err)
+
+		return PatternOptions{
+			Pattern: app.Preferences().StringWithFallback("pattern", PatternNone),
+			Cycles:  cycles,
+			Width:   width,
+			Density: density,
+			Mix:     mix,
+		}
+	}
+
+	// gets the random walk options from the settings
+	readWalkOptions := func() *WalkOptions {
+		minKey, err := strconv.Atoi(app.Preferences().StringWithFallback("walkMinKey", "36"))
+		handleErr(err)
+		maxKey, err := strconv.Atoi(app.Preferences().StringWithFallback("walkMaxKey", "96"))
+		handleErr(err)
+		maxStep, err := strconv.Atoi(app.Preferences().StringWithFallback("walkMaxStep", "3"))
+		handleErr(err)
+
+		root := 0
+		for i, name := range keyNames {
+			if name == app.Preferences().StringWithFallback("walkRoot", "C") {
+				root = i
+			}
+		}
+
+		return &WalkOptions{
+			MinKey:       uint8(minKey),
+			MaxKey:       uint8(maxKey),
+			MaxStep:      maxStep,
+			Distribution: app.Preferences().StringWithFallback("walkDistribution", StepUniform),
+			Scale:        app.Preferences().StringWithFallback("walkScale", "None"),
+			Root:         root,
+		}
+	}
+
+	// gets the rhythm options from the settings
+	readRhythmOptions := func() RhythmOptions {
+		pulses, err := strconv.Atoi(app.Preferences().StringWithFallback("rhythmPulses", "3"))
+		handleErr(err)
+		steps, err := strconv.Atoi(app.Preferences().StringWithFallback("rhythmSteps", "8"))
+		handleErr(err)
+
+		return RhythmOptions{
+			Rhythm:  app.Preferences().StringWithFallback("rhythm", RhythmNone),
+			Pulses:  pulses,
+			Steps:   steps,
+			Pattern: app.Preferences().StringWithFallback("rhythmPattern", "x..x..x."),
+		}
+	}
+
+	// gets the chord options from the settings
+	readChordOptions := func() ChordOptions {
+		size, err := strconv.Atoi(app.Preferences().StringWithFallback("chordSize", "3"))
+		handleErr(err)
+		spread, err := strconv.Atoi(app.Preferences().StringWithFallback("chordSpread", "12"))
+		handleErr(err)
+
+		return ChordOptions{
+			Chord:  app.Preferences().StringWithFallback("chord", ChordNone),
+			Size:   size,
+			Spread: spread,
+		}
+	}
+
+	// gets the controller options from the settings
+	readControllerOptions := func() ControllerOptions {
+		resolution, err := strconv.Atoi(app.Preferences().StringWithFallback("controllerResolution", "24"))
+		handleErr(err)
+		cycles, err := strconv.Atoi(app.Preferences().StringWithFallback("controllerCycles", "4"))
+		handleErr(err)
+		minValue, err := strconv.Atoi(app.Preferences().StringWithFallback("controllerMin", "0"))
+		handleErr(err)
+		maxValue, err := strconv.Atoi(app.Preferences().StringWithFallback("controllerMax", "127"))
+		handleErr(err)
+
+		return ControllerOptions{
+			Lanes:      parseLanes(app.Preferences().StringWithFallback("controllerLanes", "")),
+			Shape:      app.Preferences().StringWithFallback("controllerShape", ShapeLFO),
+			Resolution: resolution,
+			Cycles:     cycles,
+			Min:        minValue,
+			Max:        maxValue,
+		}
+	}
+
+	// validates the inputs, and generates the tracks
+	// returns nil if any of the inputs are invalid
+	generate := func() *generation {
 		var errors []string
 
 		// validate all inputs
@@ -204,71 +896,146 @@ func createGUI() {
 			errors = append(errors, "velocity (other settings): min cannot be greater than max")
 		}
 
+		// image mode needs an image which can be read
+		mode := app.Preferences().StringWithFallback("generationMode", ModeRandom)
+		imagePath := app.Preferences().StringWithFallback("imagePath", "")
+		imageOpts := readImageOptions()
+		var img image.Image
+		if mode == ModeImage {
+			if imagePath == "" {
+				errors = append(errors, "image (other settings): no image selected")
+			} else if img, err = loadImage(imagePath); err != nil {
+				errors = append(errors, "image (other settings): "+err.Error())
+			}
+			if imageOpts.MinKey > imageOpts.MaxKey {
+				errors = append(errors, "image keys (other settings): lowest key cannot be higher than highest key")
+			}
+		}
+
+		// text mode needs some text
+		textOpts := readTextOptions()
+		if mode == ModeText {
+			if strings.TrimSpace(textOpts.Text) == "" {
+				errors = append(errors, "text (other settings): no text entered")
+			}
+			if textOpts.MinKey > textOpts.MaxKey {
+				errors = append(errors, "text keys (other settings): lowest key cannot be higher than highest key")
+			}
+		}
+
+		// random walk mode needs a key range to walk in
+		walkOpts := readWalkOptions()
+		if mode == ModeWalk && walkOpts.MinKey > walkOpts.MaxKey {
+			errors = append(errors, "walk keys (other settings): lowest key cannot be higher than highest key")
+		}
+
+		// markov chain mode needs a model, learned from a midi or loaded from json
+		markovPath := app.Preferences().StringWithFallback("markovPath", "")
+		markovOrder, err := strconv.Atoi(app.Preferences().StringWithFallback("markovOrder", "2"))
+		handleErr(err)
+		markovTemperature, err := strconv.Atoi(app.Preferences().StringWithFallback("markovTemperature", "100"))
+		handleErr(err)
+		var model MarkovModel
+		if mode == ModeMarkov {
+			if markovPath == "" {
+				errors = append(errors, "markov (other settings): no model selected")
+			} else if model, err = loadMarkovModel(markovPath, markovOrder); err != nil {
+				errors = append(errors, "markov (other settings): "+err.Error())
+			}
+		}
+
+		// note lengths include both the min and max, so they only need to be in order
+		lengthOpts := LengthOptions{
+			Distribution: app.Preferences().StringWithFallback("lengthDistribution", LengthUniform),
+			Dotted:       app.Preferences().BoolWithFallback("dottedLengths", false),
+			Triplets:     app.Preferences().BoolWithFallback("tripletLengths", false),
+		}
+		minLength, minErr := strconv.Atoi(MinNoteLenNumInput.Text)
+		maxLength, maxErr := strconv.Atoi(MaxNoteLenNuminput.Text)
+		if minErr != nil || maxErr != nil {
+			errors = append(errors, "note length: must be a number")
+		} else if minLength > maxLength {
+			errors = append(errors, "note length: min cannot be greater than max")
+		} else if ppq, err := strconv.Atoi(PPQSelectInput.Selected); err == nil && lengthOpts.Distribution == LengthMusical {
+			if _, err := musicalLengths(lengthOpts, ppq, minLength, maxLength); err != nil {
+				errors = append(errors, "note length: "+err.Error())
+			}
+		}
+
+		rhythmOpts := readRhythmOptions()
+		if _, err := rhythmOpts.bars(); err != nil {
+			errors = append(errors, "rhythm (other settings): "+err.Error())
+		}
+
+		channelStrategy, err := parseChannelStrategy(
+			app.Preferences().StringWithFallback("channelStrategy", ChannelFromSetting),
+			app.Preferences().StringWithFallback("channelList", ""),
+		)
+		if err != nil {
+			errors = append(errors, "channels (other settings): "+err.Error())
+		}
+
+		palette, err := readPaletteOptions()
+		if err != nil {
+			errors = append(errors, "palette (other settings): "+err.Error())
+		}
+
+		controllerOpts := readControllerOptions()
+		if len(controllerOpts.Lanes) > 0 && controllerOpts.Min > controllerOpts.Max {
+			errors = append(errors, "controllers (other settings): min value cannot be greater than max value")
+		}
+
 		if len(errors) > 0 {
 			// if there are any errors show them in a dialog, and do not continue
 			dialog.ShowInformation("Invalid Options", strings.Join(errors, "\n"), window)
-		} else {
-			// if there are no errors, create the midi file
-			OutputLogTxt.SetText("")
+			return nil
+		}
 
-			// get values from inputs, converting to correct types
-			noteCount, err := strconv.Atoi(NotesNumInput.Text)
-			handleErr(err)
-			ticks, err := strconv.Atoi(TicksNumInput.Text)
-			handleErr(err)
-			minNoteLength, err := strconv.Atoi(MinNoteLenNumInput.Text)
-			handleErr(err)
-			maxNoteLength, err := strconv.Atoi(MaxNoteLenNuminput.Text)
-			handleErr(err)
-			maxNotesPerTrack, err := strconv.Atoi(app.Preferences().StringWithFallback("maxNotesPerTrack", "1000"))
-			handleErr(err)
-			ppq, err := strconv.Atoi(PPQSelectInput.Selected)
-			handleErr(err)
-			bpm, err := strconv.Atoi(BPMNumInput.Text)
-			handleErr(err)
-			trimNotes := app.Preferences().BoolWithFallback("trimNotes", true)
-			noteChannel := app.Preferences().StringWithFallback("noteChannel", "16")
-
-			// if user selected MIDI Bars, convert the bars to ticks
-			lengthType := app.Preferences().StringWithFallback("lengthType", "MIDI Ticks")
-
-			if lengthType == "MIDI Bars" {
-				// ticks rn is the number of bars
-				// so we need to convert it to ticks, by multiplying it by the ppq
-				// ppq is the number of ticks per quarter note, so we need to multiply it by 4
-				// ticks = bars * ppq * 4
-				ticks = int(float64(ticks) * float64(ppq) * 4)
-			}
-
-			// disable all inputs
-			OutputPathTxtInput.Disable()
-			TicksNumInput.Disable()
-			MinNoteLenNumInput.Disable()
-			MaxNoteLenNuminput.Disable()
-			PPQSelectInput.Disable()
-			BPMNumInput.Disable()
-			window.SetTitle("Random Note Generator (Running...)")
-			// TODO: add a cancel button
-			// TODO: disable the create button
-
-			// log the values
-			OutputLogTxt.SetText(
-				fmt.Sprintf(
-					"creating tracks | nc: %d | len: %d | maxlen: %d | minlen: %d | notesper: %d | trimnotes: %t | velocity: %d-%d | channel: %v\n",
-					noteCount,
-					ticks,
-					maxNoteLength,
-					minNoteLength,
-					maxNotesPerTrack,
-					trimNotes,
-					minVelocity,
-					maxVelocity,
-					noteChannel,
-				),
-			)
+		// if there are no errors, create the tracks
+		OutputLogTxt.SetText("")
 
-			// create the tracks
-			tracks := createTracks(
+		// get values from inputs, converting to correct types
+		noteCount, err := strconv.Atoi(NotesNumInput.Text)
+		handleErr(err)
+		ticks, err := strconv.Atoi(TicksNumInput.Text)
+		handleErr(err)
+		minNoteLength, err := strconv.Atoi(MinNoteLenNumInput.Text)
+		handleErr(err)
+		maxNoteLength, err := strconv.Atoi(MaxNoteLenNuminput.Text)
+		handleErr(err)
+		maxNotesPerTrack, err := strconv.Atoi(app.Preferences().StringWithFallback("maxNotesPerTrack", "1000"))
+		handleErr(err)
+		ppq, err := strconv.Atoi(PPQSelectInput.Selected)
+		handleErr(err)
+		bpm, err := strconv.Atoi(BPMNumInput.Text)
+		handleErr(err)
+		trimNotes := app.Preferences().BoolWithFallback("trimNotes", true)
+		noteChannel := app.Preferences().StringWithFallback("noteChannel", "16")
+		maxPolyphony, err := strconv.Atoi(app.Preferences().StringWithFallback("maxPolyphony", "0"))
+		handleErr(err)
+		gate, err := strconv.Atoi(app.Preferences().StringWithFallback("gate", "0"))
+		handleErr(err)
+		trackSplitCount, err := strconv.Atoi(app.Preferences().StringWithFallback("trackCount", "16"))
+		handleErr(err)
+		trackSplitKeys, err := strconv.Atoi(app.Preferences().StringWithFallback("trackKeys", "12"))
+		handleErr(err)
+		split := readSplitPolicy()
+
+		// if user selected MIDI Bars, convert the bars to ticks
+		lengthType := app.Preferences().StringWithFallback("lengthType", "MIDI Ticks")
+
+		if lengthType == "MIDI Bars" {
+			// ticks rn is the number of bars
+			// so we need to convert it to ticks, by multiplying it by the ppq
+			// ppq is the number of ticks per quarter note, so we need to multiply it by 4
+			// ticks = bars * ppq * 4
+			ticks = int(float64(ticks) * float64(ppq) * 4)
+		}
+
+		// log the values
+		OutputLogTxt.SetText(
+			fmt.Sprintf(
+				"creating tracks | nc: %d | len: %d | maxlen: %d | minlen: %d | notesper: %d | trimnotes: %t | velocity: %d-%d | channel: %v\n",
 				noteCount,
 				ticks,
 				maxNoteLength,
@@ -278,27 +1045,407 @@ func createGUI() {
 				minVelocity,
 				maxVelocity,
 				noteChannel,
-				func(format string, args ...any) {
-					OutputLogTxt.SetText(OutputLogTxt.Text + fmt.Sprintf(format, args...) + "\n")
-				},
-			)
-			OutputLogTxt.SetText(OutputLogTxt.Text + "created tracks" + "\n")
+			),
+		)
+
+		opts := GenOptions{
+			NoteCount:        noteCount,
+			Ticks:            ticks,
+			PPQ:              ppq,
+			MinNoteLength:    minNoteLength,
+			MaxNoteLength:    maxNoteLength,
+			MaxNotesPerTrack: maxNotesPerTrack,
+			TrimNotes:        trimNotes,
+			MinVelocity:      minVelocity,
+			MaxVelocity:      maxVelocity,
+			NoteChannel:      noteChannel,
+			Pattern:          readPatternOptions(),
+			Rhythm:           rhythmOpts,
+			Chords:           readChordOptions(),
+			MaxPolyphony:     maxPolyphony,
+			Lengths:          lengthOpts,
+			Gate:             gate,
+			Channels:         channelStrategy,
+			TrackSplit: TrackSplitOptions{
+				Split:  app.Preferences().StringWithFallback("trackSplit", TrackSplitFill),
+				Tracks: trackSplitCount,
+				Keys:   trackSplitKeys,
+			},
+			Controllers: controllerOpts,
+		}
+
+		// the max polyphony can only be checked once the length is known
+		if err := checkPolyphony(opts); err != nil {
+			dialog.ShowInformation("Invalid Options", "max polyphony (other settings): "+err.Error(), window)
+			return nil
+		}
+		if maxPolyphony > 0 {
+			logOutput("max polyphony: %d", maxPolyphony)
+		}
+		if gate > 0 {
+			logOutput("gate: %d%%", gate)
+		}
+		if channelStrategy.Strategy != ChannelFromSetting {
+			logOutput("channel strategy: %s", channelStrategy.Strategy)
+		}
+		if len(controllerOpts.Lanes) > 0 {
+			logOutput("controllers: %s | shape: %s | resolution: %d | values: %d-%d", strings.Join(controllerOpts.Lanes, ", "), controllerOpts.Shape, controllerOpts.Resolution, controllerOpts.Min, controllerOpts.Max)
+		}
+		if opts.Chords.Chord != ChordNone {
+			logOutput("chords: %s | notes per chord: %d | spread: %d", opts.Chords.Chord, opts.Chords.Size, opts.Chords.Spread)
+		}
+		if rhythmOpts.Rhythm != RhythmNone {
+			if _, err := rhythmOnsets(rhythmOpts, ticks, ppq); err != nil {
+				logOutput("rhythm: %v, notes will start on any tick", err)
+			} else if rhythmOpts.Rhythm == RhythmEuclidean {
+				logOutput("rhythm: E(%d,%d)", rhythmOpts.Pulses, rhythmOpts.Steps)
+			} else {
+				logOutput("rhythm: %s", rhythmOpts.Pattern)
+			}
+		}
+		if opts.Pattern.Pattern != PatternNone {
+			logOutput("pattern: %s | cycles: %d | width: %d | density: %d | mix: %d%%", opts.Pattern.Pattern, opts.Pattern.Cycles, opts.Pattern.Width, opts.Pattern.Density, opts.Pattern.Mix)
+		}
+
+		// create the tracks
+		var tracks []smf.Track
+		switch mode {
+		case ModeImage:
+			logOutput("drawing %s | keys: %d-%d | columns: %d | threshold: %.0f%%", imagePath, imageOpts.MinKey, imageOpts.MaxKey, imageOpts.Columns, imageOpts.Threshold*100)
+			tracks, err = createImageTracks(img, imageOpts, opts, logOutput)
+		case ModeWalk:
+			logOutput("walking | keys: %d-%d | max step: %d | step sizes: %s | scale: %s %s", walkOpts.MinKey, walkOpts.MaxKey, walkOpts.MaxStep, walkOpts.Distribution, keyNames[walkOpts.Root], walkOpts.Scale)
+			opts.Walk = walkOpts
+			tracks, err = createTracks(opts, logOutput)
+		case ModeMarkov:
+			logOutput("using model %s | order: %d | channels: %d | temperature: %d%%", markovPath, model.Order, len(model.Channels), markovTemperature)
+			tracks, err = createMarkovTracks(model, float64(markovTemperature)/100, ppq, opts, logOutput)
+		case ModeText:
+			logOutput("spelling %q | keys: %d-%d | direction: %s", textOpts.Text, textOpts.MinKey, textOpts.MaxKey, textOpts.Direction)
+			tracks, err = createTextTracks(textOpts, opts, logOutput)
+		default:
+			tracks, err = createTracks(opts, logOutput)
+		}
+		if err != nil {
+			// placed notes, e.g. from an image, can be too crowded for the max polyphony even when the note count fits
+			dialog.ShowInformation("Invalid Options", "max polyphony (other settings): "+err.Error(), window)
+			return nil
+		}
+		OutputLogTxt.SetText(OutputLogTxt.Text + "created tracks" + "\n")
+
+		// notes can go past the length by up to the max note length, unless they are trimmed
+		// markov note lengths come from the model instead, so they can go past it by the longest length in the model
+		maxTick := ticks
+		if !trimNotes {
+			if mode == ModeMarkov {
+				maxTick += model.longestNote(ppq)
+			} else {
+				maxTick += maxNoteLength
+			}
+		}
+
+		return finishGeneration(tracks, noteCount, ppq, bpm, maxTick, split, palette)
+	}
+
+	// validates the inputs, and converts the notes of an imported note list into tracks
+	// the notes are split into tracks, and given channels, using the same settings as generating,
+	// unless they are set to keep the tracks or channels of the note list
+	// returns nil if any of the inputs are invalid
+	importNotes := func(notes []Note) *generation {
+		var errors []string
+
+		// validate the inputs used when importing
+		if err := OutputPathTxtInput.Validate(); err != nil {
+			errors = append(errors, "output: "+err.Error())
+		}
+		if PPQSelectInput.Selected == "" {
+			errors = append(errors, "ppq: cannot be empty")
+		}
+		if err := BPMNumInput.Validate(); err != nil {
+			errors = append(errors, "bpm: "+err.Error())
+		}
+
+		palette, err := readPaletteOptions()
+		if err != nil {
+			errors = append(errors, "palette (other settings): "+err.Error())
+		}
+
+		channelStrategy, err := parseChannelStrategy(
+			app.Preferences().StringWithFallback("channelStrategy", ChannelFromSetting),
+			app.Preferences().StringWithFallback("channelList", ""),
+		)
+		if err != nil && !app.Preferences().BoolWithFallback("importKeepChannels", false) {
+			errors = append(errors, "channels (other settings): "+err.Error())
+		}
+
+		if len(errors) > 0 {
+			// if there are any errors show them in a dialog, and do not continue
+			dialog.ShowInformation("Invalid Options", strings.Join(errors, "\n"), window)
+			return nil
+		}
+
+		OutputLogTxt.SetText("")
+
+		// get values from inputs, converting to correct types
+		maxNotesPerTrack, err := strconv.Atoi(app.Preferences().StringWithFallback("maxNotesPerTrack", "1000"))
+		handleErr(err)
+		ppq, err := strconv.Atoi(PPQSelectInput.Selected)
+		handleErr(err)
+		bpm, err := strconv.Atoi(BPMNumInput.Text)
+		handleErr(err)
+		noteChannel := app.Preferences().StringWithFallback("noteChannel", "16")
+		maxPolyphony, err := strconv.Atoi(app.Preferences().StringWithFallback("maxPolyphony", "0"))
+		handleErr(err)
+		gate, err := strconv.Atoi(app.Preferences().StringWithFallback("gate", "0"))
+		handleErr(err)
+		trackSplitCount, err := strconv.Atoi(app.Preferences().StringWithFallback("trackCount", "16"))
+		handleErr(err)
+		trackSplitKeys, err := strconv.Atoi(app.Preferences().StringWithFallback("trackKeys", "12"))
+		handleErr(err)
+		keepTracks := app.Preferences().BoolWithFallback("importKeepTracks", false)
+		keepChannels := app.Preferences().BoolWithFallback("importKeepChannels", false)
+		split := readSplitPolicy()
+
+		// log the values
+		logOutput("importing notes | nc: %d | notesper: %d | channel: %v | strategy: %s | polyphony: %d | gate: %d | keeptracks: %t | keepchannels: %t", len(notes), maxNotesPerTrack, noteChannel, channelStrategy.Strategy, maxPolyphony, gate, keepTracks, keepChannels)
+
+		// the midi ends with the last note
+		maxTick := 0
+		for _, note := range notes {
+			if int(note.End) > maxTick {
+				maxTick = int(note.End)
+			}
+		}
+
+		// the notes are already made, so only the settings used to put them into tracks matter
+		opts := GenOptions{
+			NoteCount:        len(notes),
+			Ticks:            maxTick,
+			MaxNotesPerTrack: maxNotesPerTrack,
+			TrimNotes:        true,
+			NoteChannel:      noteChannel,
+			MaxPolyphony:     maxPolyphony,
+			Gate:             gate,
+			Channels:         channelStrategy,
+			TrackSplit: TrackSplitOptions{
+				Split:  app.Preferences().StringWithFallback("trackSplit", TrackSplitFill),
+				Tracks: trackSplitCount,
+				Keys:   trackSplitKeys,
+			},
+			Controllers: readControllerOptions(),
+		}
+
+		// create the tracks
+		tracks, err := createTracksFromNotes(notes, opts, keepTracks, keepChannels, logOutput)
+		if err != nil {
+			dialog.ShowInformation("Invalid Options", "max polyphony (other settings): "+err.Error(), window)
+			return nil
+		}
+		OutputLogTxt.SetText(OutputLogTxt.Text + "created tracks" + "\n")
+
+		return finishGeneration(tracks, len(notes), ppq, bpm, maxTick, split, palette)
+	}
+
+	// saves the generated tracks to midi files, and the note list if enabled, asking before overwriting any existing files
+	// enables all inputs once done
+	save := func(gen *generation) {
+		var (
+			midiPath = OutputPathTxtInput.Text
+			parts    = splitTracks(gen.tracks, gen.split)
+			files    []string
+		)
+
+		if !gen.listOnly || gen.noteList == NoteListNone {
+			files = outputFiles(midiPath, len(parts))
+			if gen.palette.File != PaletteFileNone {
+				files = append(files, paletteFilePath(midiPath, gen.palette.File))
+			}
+		}
+		if gen.noteList != NoteListNone {
+			files = append(files, noteListPath(midiPath, gen.noteList))
+		}
+
+		// save the tracks to a midi file
+		write := func() {
+			if gen.noteList != NoteListNone {
+				listPath := noteListPath(midiPath, gen.noteList)
+				err := writeFileAtomic(listPath, func(w io.Writer) error {
+					return writeNoteList(w, gen.noteList, gen.notes, newConstantTempoMap(gen.ppq, gen.bpm))
+				})
+				handleErr(err)
+				logOutput("saved %d notes to %s", len(gen.notes), listPath)
+
+				if gen.listOnly {
+					enableInputs()
+					return
+				}
+			}
+
+			// every part starts its tracks from 1, so the palette covers the part with the most tracks
+			if gen.palette.File != PaletteFileNone {
+				trackCount := 0
+				for _, part := range parts {
+					if len(part)+1 > trackCount {
+						trackCount = len(part) + 1
+					}
+				}
+
+				palettePath := paletteFilePath(midiPath, gen.palette.File)
+				err := writeFileAtomic(palettePath, func(w io.Writer) error {
+					return writePaletteFile(w, gen.palette, trackCount)
+				})
+				handleErr(err)
+				logOutput("saved the palette to %s", palettePath)
+			}
 
-			// save the tracks to a midi file
 			OutputLogTxt.SetText(OutputLogTxt.Text + "saving to midi" + "\n")
-			createMIDI(OutputPathTxtInput.Text, ppq, bpm, tracks, func() {
+			createMIDI(midiPath, gen.ppq, gen.bpm, parts, logOutput, func() {
 				OutputLogTxt.SetText(OutputLogTxt.Text + "saved to midi" + "\n")
 
+				// re-read what was written to make sure it matches what was generated
+				OutputLogTxt.SetText(OutputLogTxt.Text + "verifying midi" + "\n")
+				if problems := verifyOutput(midiPath, parts, gen.noteCount, uint32(gen.maxTick)); len(problems) > 0 {
+					for _, problem := range problems {
+						logOutput("verification failed: %s", problem)
+					}
+					dialog.ShowInformation("Verification Failed", fmt.Sprintf("The saved midi does not match what was generated, %d problems were found. See the output for details.", len(problems)), window)
+				} else {
+					logOutput("verified %d notes", gen.noteCount)
+				}
+
 				// after the midi file is saved, enable all inputs
-				OutputPathTxtInput.Enable()
-				TicksNumInput.Enable()
-				MinNoteLenNumInput.Enable()
-				MaxNoteLenNuminput.Enable()
-				PPQSelectInput.Enable()
-				BPMNumInput.Enable()
-				window.SetTitle("Random Note Generator")
+				enableInputs()
 			})
 		}
+
+		// ask before overwriting any existing files
+		var existing []string
+		for _, file := range files {
+			if _, err := os.Stat(file); err == nil {
+				existing = append(existing, file)
+			}
+		}
+
+		if len(existing) > 0 {
+			// only list the first few files, split outputs can have a lot of parts
+			listed := existing
+			if len(listed) > 10 {
+				listed = append(listed[:10:10], fmt.Sprintf("...and %d more", len(existing)-10))
+			}
+
+			dialog.ShowConfirm("Overwrite Files", "These files already exist:\n"+strings.Join(listed, "\n")+"\n\nDo you want to overwrite them?", func(b bool) {
+				if !b {
+					OutputLogTxt.SetText(OutputLogTxt.Text + "cancelled, nothing was saved" + "\n")
+					enableInputs()
+					return
+				}
+				write()
+			}, window)
+		} else {
+			write()
+		}
+	}
+
+	// tabs below the inputs
+	// the preview tab is selected when previewing
+	PreviewTab := container.NewTabItem("Preview", container.NewBorder(
+		nil,
+		container.NewBorder(nil, nil, createTxt("Zoom:"), container.NewHBox(
+			widget.NewButtonWithIcon("Regenerate", theme.ViewRefreshIcon(), func() {
+				disableInputs()
+				generate()
+				enableInputs()
+			}),
+			widget.NewButtonWithIcon("Save", theme.DocumentSaveIcon(), func() {
+				if lastGeneration == nil {
+					dialog.ShowInformation("Nothing to Save", "Preview or create a midi first", window)
+					return
+				}
+				disableInputs()
+				save(lastGeneration)
+			}),
+			widget.NewButtonWithIcon("Export Image", theme.MediaPhotoIcon(), func() {
+				if lastGeneration == nil {
+					dialog.ShowInformation("Nothing to Export", "Preview or create a midi first", window)
+					return
+				}
+				showImageExportDialog(app, window, previewNotes)
+			}),
+			widget.NewButtonWithIcon("Export Audio", theme.MediaMusicIcon(), func() {
+				if lastGeneration == nil {
+					dialog.ShowInformation("Nothing to Export", "Preview or create a midi first", window)
+					return
+				}
+				showAudioExportDialog(app, window, previewNotes, newConstantTempoMap(lastGeneration.ppq, lastGeneration.bpm))
+			}),
+		), PreviewZoomSlider),
+		nil,
+		nil,
+		PreviewScroll,
+	))
+	Tabs := container.NewAppTabs(
+		container.NewTabItem("Output", container.New(
+			layout.NewMaxLayout(),
+			OutputLogTxt,
+		)),
+		container.NewTabItem("Statistics", container.NewBorder(
+			nil,
+			container.NewHBox(AnalyzeMIDIBTN, SaveStatsBTN),
+			nil,
+			nil,
+			StatsTxt,
+		)),
+		PreviewTab,
+	)
+
+	// preview button
+	// generates the tracks without saving them, so they can be regenerated until they look right
+	PreviewBTN := widget.NewButton("Preview", func() {
+		disableInputs()
+		if generate() != nil {
+			Tabs.Select(PreviewTab)
+			renderPreview() // render again, now the preview has a size
+		}
+		enableInputs()
+	})
+
+	// import button
+	// user can select a csv or json note list, which is converted and saved the same way as generated notes
+	ImportBTN := widget.NewButton("Import Notes", func() {
+		fileDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, _ error) {
+			if reader == nil { // if the user did not select a file
+				return
+			}
+			defer reader.Close()
+
+			notes, err := readNoteList(reader, reader.URI().Name())
+			if err != nil {
+				dialog.ShowError(err, window)
+				return
+			}
+
+			disableInputs()
+			gen := importNotes(notes)
+			if gen == nil {
+				enableInputs()
+				return
+			}
+			save(gen)
+		}, window)
+
+		fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".csv", ".json", ".jsonl"}))
+		fileDialog.Show()
+	})
+
+	// create button
+	CreateBTN := widget.NewButton("Create", func() {
+		disableInputs()
+		gen := generate()
+		if gen == nil {
+			enableInputs()
+			return
+		}
+		save(gen)
 	})
 
 	// set default values
@@ -337,18 +1484,12 @@ func createGUI() {
 				container.New(layout.NewFormLayout(), MinNoteLenNumLbl, MinNoteLenNumInput),
 				container.New(layout.NewFormLayout(), MaxNoteLenNumLbl, MaxNoteLenNuminput),
 			),
-			CreateBTN,
+			container.New(layout.NewGridLayout(3), ImportBTN, PreviewBTN, CreateBTN),
 		),
 		HelpBar,
 		nil,
 		nil,
-		container.New(
-			layout.NewMaxLayout(),
-			container.New(
-				layout.NewMaxLayout(),
-				OutputLogTxt,
-			),
-		),
+		Tabs,
 	)
 
 	// set close intercept to save settings
@@ -364,6 +1505,16 @@ func createGUI() {
 		window.Close()
 	})
 
+	// tools menu
+	// hosts tools which work on any midi, not just generated ones
+	window.SetMainMenu(fyne.NewMainMenu(
+		fyne.NewMenu("Tools",
+			fyne.NewMenuItem("Dump MIDI to Text...", func() { showDumpMIDIDialog(window) }),
+			fyne.NewMenuItem("Rebuild MIDI from Text...", func() { showRebuildMIDIDialog(window) }),
+			fyne.NewMenuItem("Save Markov Model...", func() { showSaveMarkovDialog(app, window) }),
+		),
+	))
+
 	// set content and show
 	window.SetContent(content)
 	window.ShowAndRun()
@@ -405,3 +1556,302 @@ func createNumberInput(min int, max int) *widget.Entry {
 	}
 	return entry
 }
+
+// Validates every input of a form which has a validator, returning a problem for each invalid input
+// This is used instead of widget.Form's own validation, which only checks the inputs once the form is shown
+func validateForm(form *widget.Form) []string {
+	var problems []string
+	for _, item := range form.Items {
+		if input, ok := item.Widget.(fyne.Validatable); ok {
+			if err := input.Validate(); err != nil {
+				problems = append(problems, strings.ToLower(item.Text)+": "+err.Error())
+			}
+		}
+	}
+	return problems
+}
+
+// Shows a form with the piano roll image settings, then asks where to save the image
+// The image is saved as svg if the file ends in .svg, otherwise as png
+func showImageExportDialog(app fyne.App, window fyne.Window, notes []Note) {
+	WidthNumInput := createNumberInput(1, -1)
+	HeightNumInput := createNumberInput(1, -1)
+	MinKeyNumInput := createNumberInput(0, 127)
+	MaxKeyNumInput := createNumberInput(0, 127)
+	ColorBySelectInput := widget.NewSelect([]string{"Track", "Channel"}, func(string) {})
+	ColorsTxtInput := widget.NewEntry()
+	ColorsTxtInput.Validator = func(input string) error {
+		_, err := parseColors(input)
+		return err
+	}
+
+	FormItems := []*widget.FormItem{
+		widget.NewFormItem("Width", WidthNumInput),
+		widget.NewFormItem("Height", HeightNumInput),
+		widget.NewFormItem("Lowest Key", MinKeyNumInput),
+		widget.NewFormItem("Highest Key", MaxKeyNumInput),
+		widget.NewFormItem("Color By", ColorBySelectInput),
+		widget.NewFormItem("Colors", ColorsTxtInput),
+	}
+
+	// set default values
+	WidthNumInput.SetText(app.Preferences().StringWithFallback("imageWidth", "1920"))
+	HeightNumInput.SetText(app.Preferences().StringWithFallback("imageHeight", "1080"))
+	MinKeyNumInput.SetText(app.Preferences().StringWithFallback("imageMinKey", "0"))
+	MaxKeyNumInput.SetText(app.Preferences().StringWithFallback("imageMaxKey", "127"))
+	ColorBySelectInput.SetSelected(app.Preferences().StringWithFallback("imageColorBy", "Track"))
+	ColorsTxtInput.SetText(app.Preferences().StringWithFallback("imageColors", formatColors(defaultColors)))
+
+	dialog.ShowForm("Export Image", "Export", "Cancel", FormItems, func(b bool) {
+		if !b {
+			return
+		}
+
+		// save values
+		app.Preferences().SetString("imageWidth", WidthNumInput.Text)
+		app.Preferences().SetString("imageHeight", HeightNumInput.Text)
+		app.Preferences().SetString("imageMinKey", MinKeyNumInput.Text)
+		app.Preferences().SetString("imageMaxKey", MaxKeyNumInput.Text)
+		app.Preferences().SetString("imageColorBy", ColorBySelectInput.Selected)
+		app.Preferences().SetString("imageColors", ColorsTxtInput.Text)
+
+		// get values from inputs, converting to correct types
+		width, err := strconv.Atoi(WidthNumInput.Text)
+		handleErr(err)
+		height, err := strconv.Atoi(HeightNumInput.Text)
+		handleErr(err)
+		minKey, err := strconv.Atoi(MinKeyNumInput.Text)
+		handleErr(err)
+		maxKey, err := strconv.Atoi(MaxKeyNumInput.Text)
+		handleErr(err)
+		colors, err := parseColors(ColorsTxtInput.Text)
+		handleErr(err)
+
+		if minKey > maxKey {
+			dialog.ShowInformation("Invalid Options", "keys: lowest cannot be greater than highest", window)
+			return
+		}
+
+		opts := defaultRenderOptions(width, height)
+		opts.MinKey = uint8(minKey)
+		opts.MaxKey = uint8(maxKey)
+		opts.ByChannel = ColorBySelectInput.Selected == "Channel"
+		opts.Colors = colors
+
+		fileDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, _ error) {
+			if writer == nil { // if the user did not select a file
+				return
+			}
+			defer writer.Close()
+
+			encode := encodePianoRollPNG
+			if strings.ToLower(writer.URI().Extension()) == ".svg" {
+				encode = encodePianoRollSVG
+			}
+
+			if err := encode(writer, notes, opts); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}, window)
+
+		fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".png", ".svg"}))
+		fileDialog.SetFileName("preview.png")
+		fileDialog.Show()
+	}, window)
+}
+
+// Shows a form with the synthesizer settings, then asks where to save the wav file
+// Rendering happens in the background, as it can take a while with a lot of notes
+func showAudioExportDialog(app fyne.App, window fyne.Window, notes []Note, tempos TempoMap) {
+	WaveformSelectInput := widget.NewSelect(waveforms, func(string) {})
+	SampleRateSelectInput := widget.NewSelect([]string{"22050", "44100", "48000"}, func(string) {})
+	AttackNumInput := createNumberInput(0, -1)
+	DecayNumInput := createNumberInput(0, -1)
+	SustainNumInput := createNumberInput(0, 100)
+	ReleaseNumInput := createNumberInput(0, -1)
+
+	FormItems := []*widget.FormItem{
+		widget.NewFormItem("Waveform", WaveformSelectInput),
+		widget.NewFormItem("Sample Rate", SampleRateSelectInput),
+		widget.NewFormItem("Attack (ms)", AttackNumInput),
+		widget.NewFormItem("Decay (ms)", DecayNumInput),
+		widget.NewFormItem("Sustain (%)", SustainNumInput),
+		widget.NewFormItem("Release (ms)", ReleaseNumInput),
+	}
+
+	// set default values
+	WaveformSelectInput.SetSelected(app.Preferences().StringWithFallback("audioWaveform", WaveSine))
+	SampleRateSelectInput.SetSelected(app.Preferences().StringWithFallback("audioSampleRate", "44100"))
+	AttackNumInput.SetText(app.Preferences().StringWithFallback("audioAttack", "10"))
+	DecayNumInput.SetText(app.Preferences().StringWithFallback("audioDecay", "100"))
+	SustainNumInput.SetText(app.Preferences().StringWithFallback("audioSustain", "70"))
+	ReleaseNumInput.SetText(app.Preferences().StringWithFallback("audioRelease", "200"))
+
+	dialog.ShowForm("Export Audio", "Export", "Cancel", FormItems, func(b bool) {
+		if !b {
+			return
+		}
+
+		// save values
+		app.Preferences().SetString("audioWaveform", WaveformSelectInput.Selected)
+		app.Preferences().SetString("audioSampleRate", SampleRateSelectInput.Selected)
+		app.Preferences().SetString("audioAttack", AttackNumInput.Text)
+		app.Preferences().SetString("audioDecay", DecayNumInput.Text)
+		app.Preferences().SetString("audioSustain", SustainNumInput.Text)
+		app.Preferences().SetString("audioRelease", ReleaseNumInput.Text)
+
+		// get values from inputs, converting to correct types
+		sampleRate, err := strconv.Atoi(SampleRateSelectInput.Selected)
+		handleErr(err)
+		attack, err := strconv.Atoi(AttackNumInput.Text)
+		handleErr(err)
+		decay, err := strconv.Atoi(DecayNumInput.Text)
+		handleErr(err)
+		sustain, err := strconv.Atoi(SustainNumInput.Text)
+		handleErr(err)
+		release, err := strconv.Atoi(ReleaseNumInput.Text)
+		handleErr(err)
+
+		opts := SynthOptions{
+			Waveform:   WaveformSelectInput.Selected,
+			SampleRate: sampleRate,
+			Attack:     float64(attack) / 1000,
+			Decay:      float64(decay) / 1000,
+			Sustain:    float64(sustain) / 100,
+			Release:    float64(release) / 1000,
+		}
+
+		fileDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, _ error) {
+			if writer == nil { // if the user did not select a file
+				return
+			}
+
+			progress := dialog.NewProgressInfinite("Export Audio", "Rendering audio...", window)
+			progress.Show()
+
+			go func() {
+				defer writer.Close()
+
+				err := encodeWAV(writer, renderAudio(notes, tempos, opts), opts.SampleRate)
+				progress.Hide()
+				if err != nil {
+					dialog.ShowError(err, window)
+				}
+			}()
+		}, window)
+
+		fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".wav"}))
+		fileDialog.SetFileName("preview.wav")
+		fileDialog.Show()
+	}, window)
+}
+
+// Asks for a midi, then where to save it as midicsv text
+func showDumpMIDIDialog(window fyne.Window) {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, _ error) {
+		if reader == nil { // if the user did not select a file
+			return
+		}
+		defer reader.Close()
+
+		midiData, err := smf.ReadFrom(reader)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, _ error) {
+			if writer == nil { // if the user did not select a file
+				return
+			}
+			defer writer.Close()
+
+			if err := encodeMIDICSV(writer, midiData); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}, window)
+
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".csv"}))
+		saveDialog.SetFileName(strings.TrimSuffix(reader.URI().Name(), reader.URI().Extension()) + ".csv")
+		saveDialog.Show()
+	}, window)
+
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".mid", ".midi"}))
+	openDialog.Show()
+}
+
+// Asks for midicsv text, then where to save it as a midi
+func showRebuildMIDIDialog(window fyne.Window) {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, _ error) {
+		if reader == nil { // if the user did not select a file
+			return
+		}
+		defer reader.Close()
+
+		midiData, err := decodeMIDICSV(reader)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, _ error) {
+			if writer == nil { // if the user did not select a file
+				return
+			}
+			defer writer.Close()
+
+			if _, err := midiData.WriteTo(writer); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}, window)
+
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".mid"}))
+		saveDialog.SetFileName(strings.TrimSuffix(reader.URI().Name(), reader.URI().Extension()) + ".mid")
+		saveDialog.Show()
+	}, window)
+
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".csv", ".txt"}))
+	openDialog.Show()
+}
+
+// Asks for a midi, learns a markov model from it with the order from the settings, then asks where to save it as json
+func showSaveMarkovDialog(app fyne.App, window fyne.Window) {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, _ error) {
+		if reader == nil { // if the user did not select a file
+			return
+		}
+		defer reader.Close()
+
+		midiData, err := smf.ReadFrom(reader)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		order, err := strconv.Atoi(app.Preferences().StringWithFallback("markovOrder", "2"))
+		handleErr(err)
+		model, err := trainMarkov(midiData, order)
+		if err != nil {
+			dialog.ShowError(err, window)
+			return
+		}
+
+		saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, _ error) {
+			if writer == nil { // if the user did not select a file
+				return
+			}
+			defer writer.Close()
+
+			if err := writeMarkovModel(writer, model); err != nil {
+				dialog.ShowError(err, window)
+			}
+		}, window)
+
+		saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".json"}))
+		saveDialog.SetFileName(strings.TrimSuffix(reader.URI().Name(), reader.URI().Extension()) + "_model.json")
+		saveDialog.Show()
+	}, window)
+
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".mid", ".midi"}))
+	openDialog.Show()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"image"
+	_ "image/jpeg" // register jpeg decoding for image.Decode
+	_ "image/png"  // register png decoding for image.Decode
+	"math"
+	"os"
+	"sort"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Settings for turning an image into notes
+type ImageOptions struct {
+	MinKey    uint8   // key of the bottom row of the image
+	MaxKey    uint8   // key of the top row of the image
+	Columns   int     // number of time slices the image is split into, 0 to use one per pixel
+	Threshold float64 // how much ink a cell needs to become a note, from 0 to 1
+}
+
+// A single cell of the image, after it has been scaled down to keys and time slices
+type imageCell struct {
+	row, column int
+	ink         float64 // how dark or colorful the cell is, from 0 to 1
+	hue         float64 // from 0 to 360
+	saturation  float64
+}
+
+// Reads a png or jpeg image
+func loadImage(imagePath string) (image.Image, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// Creates an array of tracks which draw the image in the piano roll
+// Rows are mapped to keys and columns to time slices, and notes are placed where the image is dark or colored
+// The color of a note picks its channel, and how much ink it has picks its velocity
+// To keep the note count exact, only the cells with the most ink are used if there are too many, and random
+// notes are added if there are too few
+// Returns an error if the notes of a track cannot fit under the max polyphony
+func createImageTracks(img image.Image, imageOpts ImageOptions, opts GenOptions, logger func(format string, a ...any)) ([]smf.Track, error) {
+	var (
+		cells = imageCells(img, imageOpts)
+		notes []Note
+	)
+
+	// cells with the most ink first, so raising the threshold drops the faintest cells
+	sort.SliceStable(cells, func(i, j int) bool { return cells[i].ink > cells[j].ink })
+	used := 0
+	for used < len(cells) && used < opts.NoteCount && cells[used].ink > imageOpts.Threshold {
+		used++
+	}
+	placed := cells[:used]
+
+	// then in the order they are drawn, so every track covers one stretch of the image instead of being scattered over it
+	sort.SliceStable(placed, func(i, j int) bool {
+		if placed[i].column != placed[j].column {
+			return placed[i].column < placed[j].column
+		}
+		return placed[i].row < placed[j].row
+	})
+
+	columns := imageColumns(img, imageOpts)
+	sliceLength := float64(opts.Ticks) / float64(columns)
+
+	for _, cell := range placed {
+		start := uint32(float64(cell.column) * sliceLength)
+		end := uint32(float64(cell.column+1) * sliceLength)
+		if end <= start {
+			end = start + 1
+		}
+
+		notes = append(notes, Note{
+			Channel:  hueChannel(cell.hue, cell.saturation),
+			Key:      imageOpts.MaxKey - uint8(cell.row),
+			Velocity: uint8(float64(opts.MinVelocity) + cell.ink*float64(opts.MaxVelocity-opts.MinVelocity)),
+			Start:    start,
+			End:      end,
+		})
+	}
+
+	logger("placed %d notes from the image (%d cells had ink)", len(notes), len(cells))
+
+	tracks, err := createTracksByChannel(notes, opts, logger)
+	if err != nil {
+		return nil, err
+	}
+	return padTracks(tracks, len(notes), opts, logger)
+}
+
+// Gets the number of time slices the image is split into
+func imageColumns(img image.Image, imageOpts ImageOptions) int {
+	if imageOpts.Columns > 0 {
+		return imageOpts.Columns
+	}
+	return img.Bounds().Dx()
+}
+
+// Scales the image down to one cell per key and time slice, averaging the pixels in each cell
+// Only cells with some ink are returned
+func imageCells(img image.Image, imageOpts ImageOptions) []imageCell {
+	var (
+		bounds  = img.Bounds()
+		rows    = int(imageOpts.MaxKey) - int(imageOpts.MinKey) + 1
+		columns = imageColumns(img, imageOpts)
+		cells   []imageCell
+	)
+
+	if rows <= 0 || columns <= 0 || bounds.Empty() {
+		return nil
+	}
+
+	for row := 0; row < rows; row++ {
+		y0 := bounds.Min.Y + row*bounds.Dy()/rows
+		y1 := bounds.Min.Y + (row+1)*bounds.Dy()/rows
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for column := 0; column < columns; column++ {
+			x0 := bounds.Min.X + column*bounds.Dx()/columns
+			x1 := bounds.Min.X + (column+1)*bounds.Dx()/columns
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			// average the color of every pixel in the cell
+			var r, g, b, a, count float64
+			for y := y0; y < y1 && y < bounds.Max.Y; y++ {
+				for x := x0; x < x1 && x < bounds.Max.X; x++ {
+					pr, pg, pb, pa := img.At(x, y).RGBA()
+					r += float64(pr) / 0xffff
+					g += float64(pg) / 0xffff
+					b += float64(pb) / 0xffff
+					a += float64(pa) / 0xffff
+					count++
+				}
+			}
+			if count == 0 || a == 0 {
+				continue
+			}
+
+			// colors are premultiplied by alpha, so un-premultiply them before working out the hue
+			r, g, b, a = r/a, g/a, b/a, a/count
+			hue, saturation, luminance := hsl(r, g, b)
+
+			// dark pixels and colorful pixels both count as ink, transparent pixels do not
+			ink := math.Max(1-luminance, saturation) * a
+			if ink > 0 {
+				cells = append(cells, imageCell{row, column, ink, hue, saturation})
+			}
+		}
+	}
+
+	return cells
+}
+
+// Converts a color, with each part from 0 to 1, to its hue (0 - 360), saturation and luminance (0 - 1)
+func hsl(r, g, b float64) (float64, float64, float64) {
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	luminance := (max + min) / 2
+
+	if max == min {
+		return 0, 0, luminance
+	}
+
+	delta := max - min
+	saturation := delta / (1 - math.Abs(2*luminance-1))
+
+	var hue float64
+	switch max {
+	case r:
+		hue = math.Mod((g-b)/delta, 6)
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+
+	return hue, math.Min(saturation, 1), luminance
+}
+
+// Picks a channel for a color, spreading the hues over every channel but drums
+// Grays, which have no real hue, use the first channel
+func hueChannel(hue float64, saturation float64) uint8 {
+	if saturation < 0.2 {
+		return 0
+	}
+
+	// 15 channels, skipping 10 (drums), for the hues 0 - 360
+	channel := 1 + int(hue/360*14)
+	if channel >= 9 {
+		channel++
+	}
+	if channel > 15 {
+		channel = 15
+	}
+	return uint8(channel)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Note length distributions shown in the settings dialog
+const (
+	LengthUniform     = "Uniform"     // every length between min and max is as likely
+	LengthExponential = "Exponential" // short notes are common, long notes are rare
+	LengthLogNormal   = "Log-Normal"  // most notes are around the middle of min and max, with a few much shorter or longer
+	LengthMusical     = "Musical"     // whole notes down to 64th notes, optionally dotted or triplets
+)
+
+var lengthDistributions = []string{LengthUniform, LengthExponential, LengthLogNormal, LengthMusical}
+
+// Settings for picking the length of a note, between the min and max note length
+type LengthOptions struct {
+	Distribution string
+	Dotted       bool // musical lengths can be dotted, 1.5 times as long
+	Triplets     bool // musical lengths can be triplets, 2/3 as long
+
+	musical []int // every musical length between the min and max, filled in by createTracks
+}
+
+// Gets a random note length between the min and max note length, both included
+func noteLength(opts GenOptions) int {
+	var (
+		minNoteLength = opts.MinNoteLength
+		maxNoteLength = opts.MaxNoteLength
+		spread        = float64(maxNoteLength - minNoteLength)
+	)
+
+	if maxNoteLength <= minNoteLength {
+		return minNoteLength
+	}
+
+	switch opts.Lengths.Distribution {
+	case LengthExponential:
+		// a third of the range is the average, anything past the max is picked again
+		for {
+			length := minNoteLength + int(rand.ExpFloat64()*spread/3)
+			if length <= maxNoteLength {
+				return length
+			}
+		}
+	case LengthLogNormal:
+		// the middle is halfway between min and max on a log scale, and almost every length is within the range
+		low := math.Log(math.Max(float64(minNoteLength), 1))
+		high := math.Log(float64(maxNoteLength))
+		for {
+			length := int(math.Round(math.Exp((low+high)/2 + rand.NormFloat64()*(high-low)/4)))
+			if length >= minNoteLength && length <= maxNoteLength {
+				return length
+			}
+		}
+	case LengthMusical:
+		if lengths := opts.Lengths.musical; len(lengths) > 0 {
+			return lengths[rand.Intn(len(lengths))]
+		}
+	}
+
+	return rand.Intn(maxNoteLength-minNoteLength+1) + minNoteLength
+}
+
+// Gets every musical note length between the min and max note length, from whole notes down to 64th notes
+func musicalLengths(lengths LengthOptions, ppq int, minNoteLength int, maxNoteLength int) ([]int, error) {
+	var (
+		musical []int
+		whole   = ppq * 4
+	)
+
+	for division := 1; division <= 64; division *= 2 {
+		candidates := []int{whole / division}
+		if lengths.Dotted {
+			candidates = append(candidates, whole*3/(division*2))
+		}
+		if lengths.Triplets {
+			candidates = append(candidates, whole*2/(division*3))
+		}
+
+		for _, length := range candidates {
+			if length > 0 && length >= minNoteLength && length <= maxNoteLength {
+				musical = append(musical, length)
+			}
+		}
+	}
+
+	if len(musical) == 0 {
+		return nil, errors.New("no musical note lengths are between the min and max note length")
+	}
+	return musical, nil
+}
+
+// Sets the length of every note to a percent (opts.Gate) of the gap between its start and the next start on the
+// same channel, from staccato (10%) to legato (100%), instead of a random length
+// Notes which start together, like chords, get the same length, and the last notes use the gap to the end of the midi
+func applyGate(trackNotes [][]Note, opts GenOptions) {
+	// every start on each channel, from every track
+	var starts [16][]uint32
+	for _, notes := range trackNotes {
+		for _, note := range notes {
+			starts[note.Channel] = append(starts[note.Channel], note.Start)
+		}
+	}
+	for channel := range starts {
+		sort.Slice(starts[channel], func(i, j int) bool { return starts[channel][i] < starts[channel][j] })
+	}
+
+	for _, notes := range trackNotes {
+		for j := range notes {
+			note := &notes[j]
+			channelStarts := starts[note.Channel]
+
+			// find the first start after this note's start
+			next := uint32(opts.Ticks)
+			if k := sort.Search(len(channelStarts), func(k int) bool { return channelStarts[k] > note.Start }); k < len(channelStarts) {
+				next = channelStarts[k]
+			}
+
+			length := uint32(0)
+			if next > note.Start {
+				length = uint32(uint64(next-note.Start) * uint64(opts.Gate) / 100)
+			}
+			if length < 1 {
+				length = 1
+			}
+			note.End = note.Start + length
+		}
+	}
+}
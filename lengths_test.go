@@ -0,0 +1,40 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyGate(t *testing.T) {
+	tests := []struct {
+		name       string
+		trackNotes [][]Note
+		gate       int
+		want       [][]uint32 // end of every note
+	}{
+		{"legato", [][]Note{{{Start: 0}, {Start: 10}, {Start: 30}}}, 100, [][]uint32{{10, 30, 100}}},
+		{"staccato", [][]Note{{{Start: 0}, {Start: 10}, {Start: 30}}}, 10, [][]uint32{{1, 12, 37}}},
+		{"chord", [][]Note{{{Start: 0}, {Start: 0}, {Start: 20}}}, 50, [][]uint32{{10, 10, 60}}},
+		{"across tracks", [][]Note{{{Start: 0}}, {{Start: 20}}}, 100, [][]uint32{{20}, {100}}},
+		{"other channels", [][]Note{{{Start: 0}}, {{Start: 20, Channel: 1}}}, 100, [][]uint32{{100}, {100}}},
+		{"at least a tick", [][]Note{{{Start: 0}, {Start: 1}}}, 10, [][]uint32{{1, 10}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			applyGate(test.trackNotes, GenOptions{Ticks: 100, Gate: test.gate})
+
+			var got [][]uint32
+			for _, notes := range test.trackNotes {
+				var ends []uint32
+				for _, note := range notes {
+					ends = append(ends, note.End)
+				}
+				got = append(got, ends)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got ends %v, want %v", got, test.want)
+			}
+		})
+	}
+}
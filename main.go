@@ -2,9 +2,15 @@ package main
 
 import (
 	"log"
+	"os"
 )
 
 func main() {
+	// any flags generate a midi from the command line, without opening the gui
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	createGUI()
 }
 
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// A model of the notes of a midi, learned separately for every channel
+// Ticks are stored at the model's ppq, and scaled to the ppq of the midi being generated
+type MarkovModel struct {
+	Order    int            `json:"order"` // number of previous keys used to pick the next key
+	PPQ      int            `json:"ppq"`
+	Channels []ChannelModel `json:"channels"`
+}
+
+// The statistics of the notes on one channel
+type ChannelModel struct {
+	Channel uint8 `json:"channel"` // 0 - 15
+	Notes   int   `json:"notes"`   // number of notes the model was trained on
+
+	// next keys seen after every run of up to Order previous keys, written as space separated keys
+	// the empty context holds how often every key was seen, and is used when a context was never seen
+	Transitions map[string][]MarkovChoice `json:"transitions"`
+	Durations   []MarkovChoice            `json:"durations"`  // note lengths in ticks
+	Intervals   []MarkovChoice            `json:"intervals"`  // ticks between the starts of two notes
+	Velocities  []MarkovChoice            `json:"velocities"` // note velocities
+}
+
+// A value and the number of times it was seen
+type MarkovChoice struct {
+	Value uint32 `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Learns a markov model from the notes of a midi
+// Keys are learned as a chain of the given order, lengths, intervals and velocities as how often each was seen
+func trainMarkov(midiData *smf.SMF, order int) (MarkovModel, error) {
+	ticks, ok := midiData.TimeFormat.(smf.MetricTicks)
+	if !ok {
+		return MarkovModel{}, errors.New("the midi does not use ppq timing")
+	}
+	if order < 1 {
+		order = 1
+	}
+
+	model := MarkovModel{Order: order, PPQ: int(ticks.Resolution())}
+
+	// group the notes by channel, in the order they are played
+	var channels [16][]Note
+	for _, note := range collectNotes(midiData) {
+		channels[note.Channel] = append(channels[note.Channel], note)
+	}
+
+	for channel, notes := range channels {
+		if len(notes) == 0 {
+			continue
+		}
+		sort.SliceStable(notes, func(i, j int) bool {
+			if notes[i].Start == notes[j].Start {
+				return notes[i].Key < notes[j].Key
+			}
+			return notes[i].Start < notes[j].Start
+		})
+
+		var (
+			transitions = map[string]map[uint32]int{}
+			durations   = map[uint32]int{}
+			intervals   = map[uint32]int{}
+			velocities  = map[uint32]int{}
+		)
+		for i, note := range notes {
+			// count this key after every context of up to order keys before it, including the empty context
+			for length := 0; length <= order && length <= i; length++ {
+				context := markovContext(notes[i-length : i])
+				if transitions[context] == nil {
+					transitions[context] = map[uint32]int{}
+				}
+				transitions[context][uint32(note.Key)]++
+			}
+
+			durations[note.End-note.Start]++
+			velocities[uint32(note.Velocity)]++
+			if i > 0 {
+				intervals[note.Start-notes[i-1].Start]++
+			}
+		}
+
+		channelModel := ChannelModel{
+			Channel:     uint8(channel),
+			Notes:       len(notes),
+			Transitions: map[string][]MarkovChoice{},
+			Durations:   markovChoices(durations),
+			Intervals:   markovChoices(intervals),
+			Velocities:  markovChoices(velocities),
+		}
+		for context, next := range transitions {
+			channelModel.Transitions[context] = markovChoices(next)
+		}
+		model.Channels = append(model.Channels, channelModel)
+	}
+
+	if len(model.Channels) == 0 {
+		return MarkovModel{}, errors.New("the midi has no notes")
+	}
+	return model, nil
+}
+
+// Writes the keys of notes as a context, e.g. "60 64 67"
+func markovContext(notes []Note) string {
+	var keys []string
+	for _, note := range notes {
+		keys = append(keys, strconv.Itoa(int(note.Key)))
+	}
+	return strings.Join(keys, " ")
+}
+
+// Turns counts into a list of choices, sorted by value so the json is stable
+func markovChoices(counts map[uint32]int) []MarkovChoice {
+	var choices []MarkovChoice
+	for value, count := range counts {
+		choices = append(choices, MarkovChoice{value, count})
+	}
+	sort.Slice(choices, func(i, j int) bool { return choices[i].Value < choices[j].Value })
+	return choices
+}
+
+// Loads a markov model, from a model saved as json or by learning it from a midi
+func loadMarkovModel(modelPath string, order int) (MarkovModel, error) {
+	file, err := os.Open(modelPath)
+	if err != nil {
+		return MarkovModel{}, err
+	}
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(modelPath)) == ".json" {
+		return readMarkovModel(file)
+	}
+
+	midiData, err := smf.ReadFrom(file)
+	if err != nil {
+		return MarkovModel{}, err
+	}
+	return trainMarkov(midiData, order)
+}
+
+// Writes the model as indented json
+func writeMarkovModel(w io.Writer, model MarkovModel) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(model)
+}
+
+// Reads a model written by writeMarkovModel
+func readMarkovModel(r io.Reader) (MarkovModel, error) {
+	var model MarkovModel
+	if err := json.NewDecoder(r).Decode(&model); err != nil {
+		return MarkovModel{}, err
+	}
+
+	if model.Order < 1 || model.PPQ < 1 {
+		return MarkovModel{}, errors.New("the model is missing its order or ppq")
+	}
+	for _, channel := range model.Channels {
+		if channel.Channel > 15 {
+			return MarkovModel{}, fmt.Errorf("channel %d is not between 0 and 15", channel.Channel)
+		}
+		if channel.Notes < 1 || len(channel.Transitions[""]) == 0 || len(channel.Durations) == 0 || len(channel.Velocities) == 0 {
+			return MarkovModel{}, fmt.Errorf("channel %d has no notes", channel.Channel)
+		}
+		for _, choices := range channel.Transitions {
+			for _, choice := range choices {
+				if choice.Value > 127 {
+					return MarkovModel{}, fmt.Errorf("channel %d: key %d is not between 0 and 127", channel.Channel, choice.Value)
+				}
+			}
+		}
+		for _, choice := range channel.Velocities {
+			if choice.Value < 1 || choice.Value > 127 {
+				return MarkovModel{}, fmt.Errorf("channel %d: velocity %d is not between 1 and 127", channel.Channel, choice.Value)
+			}
+		}
+	}
+	if len(model.Channels) == 0 {
+		return MarkovModel{}, errors.New("the model has no channels")
+	}
+	return model, nil
+}
+
+// Creates an array of tracks from a markov model, with notes on the same channels as the midi it learned from
+// The note count is shared between the channels by how many notes each was trained on, and each channel plays
+// one chain of notes which starts again from the beginning when it reaches the end of the midi
+// temperature changes how closely the model is followed: 1 as learned, lower picks the most likely choices,
+// higher makes every choice more even
+// Returns an error if the notes of a track cannot fit under the max polyphony
+func createMarkovTracks(model MarkovModel, temperature float64, ppq int, opts GenOptions, logger func(format string, a ...any)) ([]smf.Track, error) {
+	var (
+		notes []Note
+		total int
+		scale = float64(ppq) / float64(model.PPQ)
+	)
+
+	for _, channel := range model.Channels {
+		total += channel.Notes
+	}
+
+	// share the notes out, giving any left over from rounding down to the first channels
+	counts := make([]int, len(model.Channels))
+	shared := 0
+	for i, channel := range model.Channels {
+		counts[i] = opts.NoteCount * channel.Notes / total
+		shared += counts[i]
+	}
+	for i := 0; shared < opts.NoteCount; i = (i + 1) % len(counts) {
+		counts[i]++
+		shared++
+	}
+
+	for i, channel := range model.Channels {
+		logger("generating %d notes from channel %d of the model", counts[i], channel.Channel+1)
+
+		var (
+			tick    float64
+			history []Note
+		)
+		for n := 0; n < counts[i]; n++ {
+			if n > 0 {
+				tick += float64(pickMarkov(channel.Intervals, temperature)) * scale
+			}
+
+			// start again from the beginning, with a new chain
+			if int(tick) >= opts.Ticks {
+				tick = 0
+				history = nil
+			}
+
+			// back off to shorter contexts until one has been seen
+			var choices []MarkovChoice
+			for length := model.Order; length >= 0; length-- {
+				if length > len(history) {
+					continue
+				}
+				if choices = channel.Transitions[markovContext(history[len(history)-length:])]; len(choices) > 0 {
+					break
+				}
+			}
+
+			var (
+				start    = int(tick)
+				duration = markovDuration(pickMarkov(channel.Durations, temperature), scale)
+				end      = start + duration
+			)
+			if opts.TrimNotes && end > opts.Ticks {
+				end = opts.Ticks
+			}
+
+			note := Note{
+				Channel:  channel.Channel,
+				Key:      uint8(pickMarkov(choices, temperature)),
+				Velocity: uint8(pickMarkov(channel.Velocities, temperature)),
+				Start:    uint32(start),
+				End:      uint32(end),
+			}
+			notes = append(notes, note)
+
+			history = append(history, note)
+			if len(history) > model.Order {
+				history = history[1:]
+			}
+		}
+	}
+
+	return createTracksByChannel(notes, opts, logger)
+}
+
+// Gets the longest note the model can create, in ticks at the given ppq
+// Used to find how far past the length of the midi untrimmed notes can go
+func (model MarkovModel) longestNote(ppq int) int {
+	var (
+		scale   = float64(ppq) / float64(model.PPQ)
+		longest = 1
+	)
+	for _, channel := range model.Channels {
+		for _, choice := range channel.Durations {
+			if duration := markovDuration(choice.Value, scale); duration > longest {
+				longest = duration
+			}
+		}
+	}
+	return longest
+}
+
+// Scales a note length of the model to the ppq of the midi, keeping every note at least 1 tick long
+func markovDuration(duration uint32, scale float64) int {
+	return int(math.Max(1, math.Round(float64(duration)*scale)))
+}
+
+// Picks a value at random, weighted by how often it was seen and the temperature
+func pickMarkov(choices []MarkovChoice, temperature float64) uint32 {
+	if len(choices) == 0 {
+		return 0
+	}
+
+	// at a temperature of 0, always pick the most common value
+	if temperature <= 0 {
+		best := choices[0]
+		for _, choice := range choices {
+			if choice.Count > best.Count {
+				best = choice
+			}
+		}
+		return best.Value
+	}
+
+	// counts are divided by the largest count first, so low temperatures do not overflow
+	var (
+		weights = make([]float64, len(choices))
+		most    = 1
+		sum     float64
+	)
+	for _, choice := range choices {
+		if choice.Count > most {
+			most = choice.Count
+		}
+	}
+	for i, choice := range choices {
+		weights[i] = math.Pow(float64(choice.Count)/float64(most), 1/temperature)
+		sum += weights[i]
+	}
+
+	target := rand.Float64() * sum
+	for i, weight := range weights {
+		if target < weight {
+			return choices[i].Value
+		}
+		target -= weight
+	}
+	return choices[len(choices)-1].Value
+}
@@ -1,215 +1,616 @@
 package main
 
 import (
+	"io"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"sort"
 
 	"gitlab.com/gomidi/midi/v2"
 	"gitlab.com/gomidi/midi/v2/smf"
 )
 
+// Generation modes shown in the settings dialog
+const (
+	ModeRandom = "Random"
+	ModeImage  = "Image"
+	ModeText   = "Text"
+	ModeWalk   = "Random Walk"
+	ModeMarkov = "Markov Chain"
+)
+
+var generationModes = []string{ModeRandom, ModeImage, ModeText, ModeWalk, ModeMarkov}
+
+// Settings for generating notes, set from the main window and the settings dialog
+type GenOptions struct {
+	NoteCount        int
+	Ticks            int // length of the midi
+	PPQ              int
+	MinNoteLength    int
+	MaxNoteLength    int
+	MaxNotesPerTrack int
+	TrimNotes        bool // cut notes off at the length of the midi
+	MinVelocity      int
+	MaxVelocity      int
+	NoteChannel      string // as selected in the settings, see parseNoteChannel
+	Pattern          PatternOptions
+	Walk             *WalkOptions // keys follow a random walk if set, otherwise they are random
+	Rhythm           RhythmOptions
+	Chords           ChordOptions
+	MaxPolyphony     int // most notes held at once in a track, 0 for no limit
+	Lengths          LengthOptions
+	Gate             int // length of notes as a percent of the gap to the next note on the same channel, 0 to use random lengths
+	Channels         ChannelStrategy
+	TrackSplit       TrackSplitOptions
+	Controllers      ControllerOptions
+}
+
 // Creates an array of tracks
-func createTracks(noteCount int, ticks int, maxNoteLength int, minNoteLength int, maxNotesPerTrack int, trimNotes bool, minVelocity int, maxVelocity int, noteChannel string, logger func(format string, a ...any)) []smf.Track {
+// Returns an error if the notes of a track cannot fit under the max polyphony
+func createTracks(opts GenOptions, logger func(format string, a ...any)) ([]smf.Track, error) {
 	var (
-		tracks               []smf.Track
+		trackNotes           [][]Note // notes of every track, built into tracks once they are all placed
+		trackChannels        []uint8
+		noteCount            = opts.NoteCount
 		remainingNotes       = noteCount
-		specifiedChannel     = -1
-		currentChannelNumber = 0
+		currentChannelNumber uint8
 		trackCount           = 0
 	)
 
-	// get channel
-	// if noteChannel is "All (Skip Drums)", set channel to -1
-	// if noteChannel is "All", set channel to -2
+	// the rhythm and lengths were checked when the settings were read, so an error here means they are not used
+	opts.Rhythm.onsets, _ = rhythmOnsets(opts.Rhythm, opts.Ticks, opts.PPQ)
+	opts.Lengths.musical, _ = musicalLengths(opts.Lengths, opts.PPQ, opts.MinNoteLength, opts.MaxNoteLength)
+
+	logger("generating notes | track split: %s", opts.TrackSplit.Split)
+	for _, nc := range trackSizes(opts) {
+		currentChannelNumber = opts.Channels.trackChannel(opts.NoteChannel, &trackCount)
+
+		// first is the index of the track's first note, out of every note
+		first := noteCount - remainingNotes
+		remainingNotes -= nc
+
+		logger("generating track (ch %d) with %d notes | notes left: %d", currentChannelNumber+1, nc, remainingNotes)
+
+		notes := placeNotes(first, nc, opts, currentChannelNumber)
+		opts.Channels.assignNoteChannels(notes)
+		trackNotes = append(trackNotes, notes)
+		trackChannels = append(trackChannels, currentChannelNumber)
+		trackCount++
+	}
+
+	trackNotes = regroupTracks(trackNotes, trackChannels, opts, logger)
+
+	tracks, err := arrangeTracks(trackNotes, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	logger("generated %d tracks", len(tracks))
+	return tracks, nil
+}
+
+// Creates tracks from the notes of every track, once they are all placed
+// Each track is kept under the max polyphony, returning an error if its notes cannot fit, then the gate is applied.
+// The gate only shortens notes to the gap to the next start, so it cannot go over the max polyphony.
+// Controller curves are added last, once the note lengths are final
+func arrangeTracks(trackNotes [][]Note, opts GenOptions) ([]smf.Track, error) {
+	tracks := make([]smf.Track, 0, len(trackNotes))
+
+	for i, notes := range trackNotes {
+		limited, err := limitPolyphony(notes, opts)
+		if err != nil {
+			return nil, err
+		}
+		trackNotes[i] = limited
+	}
+
+	// the gate needs the notes of every track on a channel, so it is applied once every track is limited
+	if opts.Gate > 0 {
+		applyGate(trackNotes, opts)
+	}
+
+	// controller curves are only added to the first track on each channel, so tracks do not send clashing values
+	var curveChannels [16]bool
+	for _, notes := range trackNotes {
+		tracks = append(tracks, buildTrack(notes, controllerEvents(notes, opts, &curveChannels)...))
+	}
+	return tracks, nil
+}
+
+// Creates an array of tracks from existing notes, e.g. an imported note list
+// Notes are split into tracks in the order given, the same way createTracks splits generated notes. If keepTracks is
+// true, notes are first grouped by the track they were in, so a track is only split when it has too many notes.
+// If keepChannels is true, notes keep their own channels, otherwise they are replaced by the channel strategy the
+// same way createTracks assigns them. Tracks are arranged the same way as generated ones, see arrangeTracks
+func createTracksFromNotes(notes []Note, opts GenOptions, keepTracks bool, keepChannels bool, logger func(format string, a ...any)) ([]smf.Track, error) {
+	var (
+		trackNotes    [][]Note
+		trackChannels []uint8
+		trackCount    = 0
+		groups        = [][]Note{notes}
+	)
+
+	if keepTracks {
+		groups = groupNotesByTrack(notes)
+		opts.TrackSplit = TrackSplitOptions{Split: TrackSplitFill}
+	}
+	if keepChannels {
+		opts.Channels = ChannelStrategy{Strategy: ChannelKeep}
+	}
+
+	for _, group := range groups {
+		groupOpts := opts
+		groupOpts.NoteCount = len(group)
+
+		start := 0
+		for _, size := range trackSizes(groupOpts) {
+			end := start + size
+			track := append([]Note(nil), group[start:end]...)
+			channel := opts.Channels.trackChannel(opts.NoteChannel, &trackCount)
+			if keepChannels {
+				logger("creating track with %d notes | notes left in track: %d", size, len(group)-end)
+			} else {
+				logger("creating track (ch %d) with %d notes | notes left in track: %d", channel+1, size, len(group)-end)
+				for i := range track {
+					track[i].Channel = channel
+				}
+				opts.Channels.assignNoteChannels(track)
+			}
+
+			trackNotes = append(trackNotes, track)
+			trackChannels = append(trackChannels, channel)
+			trackCount++
+			start = end
+		}
+	}
+
+	trackNotes = regroupTracks(trackNotes, trackChannels, opts, logger)
+
+	tracks, err := arrangeTracks(trackNotes, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	logger("created %d tracks", len(tracks))
+	return tracks, nil
+}
+
+// Groups notes by the track they are in, ordered by track number, keeping the order of the notes in each track
+func groupNotesByTrack(notes []Note) [][]Note {
+	var (
+		byTrack = map[int][]Note{}
+		numbers []int
+		groups  [][]Note
+	)
+
+	for _, note := range notes {
+		if _, ok := byTrack[note.Track]; !ok {
+			numbers = append(numbers, note.Track)
+		}
+		byTrack[note.Track] = append(byTrack[note.Track], note)
+	}
+
+	sort.Ints(numbers)
+	for _, number := range numbers {
+		groups = append(groups, byTrack[number])
+	}
+	return groups
+}
+
+// Creates an array of tracks from notes which already have a channel, e.g. notes drawn from an image
+// Notes are grouped by channel, and each group is split into tracks of up to opts.MaxNotesPerTrack notes
+// Tracks are arranged the same way as generated ones, see arrangeTracks
+func createTracksByChannel(notes []Note, opts GenOptions, logger func(format string, a ...any)) ([]smf.Track, error) {
+	var (
+		trackNotes       [][]Note
+		channels         [16][]Note
+		maxNotesPerTrack = opts.MaxNotesPerTrack
+	)
+	if maxNotesPerTrack < 1 {
+		maxNotesPerTrack = 1
+	}
+
+	for _, note := range notes {
+		channels[note.Channel] = append(channels[note.Channel], note)
+	}
+
+	for channel, channelNotes := range channels {
+		for start := 0; start < len(channelNotes); start += maxNotesPerTrack {
+			end := start + maxNotesPerTrack
+			if end > len(channelNotes) {
+				end = len(channelNotes)
+			}
+
+			logger("creating track (ch %d) with %d notes", channel+1, end-start)
+			trackNotes = append(trackNotes, append([]Note(nil), channelNotes[start:end]...))
+		}
+	}
+
+	tracks, err := arrangeTracks(trackNotes, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	logger("created %d tracks", len(tracks))
+	return tracks, nil
+}
+
+// Adds tracks of random notes after tracks made from placed notes, e.g. notes drawn from an image,
+// so there are exactly opts.NoteCount notes in total
+func padTracks(tracks []smf.Track, placed int, opts GenOptions, logger func(format string, a ...any)) ([]smf.Track, error) {
+	if placed >= opts.NoteCount {
+		return tracks, nil
+	}
+
+	padding := opts
+	padding.NoteCount = opts.NoteCount - placed
+	padding.TrackSplit = TrackSplitOptions{Split: TrackSplitFill}
+	padding.Controllers = ControllerOptions{} // the padding is arranged on its own, so it would add the curves again
+
+	logger("padding with %d random notes", padding.NoteCount)
+	paddingTracks, err := createTracks(padding, logger)
+	if err != nil {
+		return nil, err
+	}
+	return append(tracks, paddingTracks...), nil
+}
+
+// Gets the channel selected in the settings
+// if noteChannel is "All (Skip Drums)", returns -1
+// if noteChannel is "All", returns -2
+func parseNoteChannel(noteChannel string) int {
 	switch noteChannel {
 	case "All (Skip Drums)":
-		specifiedChannel = -1
+		return -1
 	case "All":
-		specifiedChannel = -2
+		return -2
 	case "1":
-		specifiedChannel = 0
+		return 0
 	case "2":
-		specifiedChannel = 1
+		return 1
 	case "3":
-		specifiedChannel = 2
+		return 2
 	case "4":
-		specifiedChannel = 3
+		return 3
 	case "5":
-		specifiedChannel = 4
+		return 4
 	case "6":
-		specifiedChannel = 5
+		return 5
 	case "7":
-		specifiedChannel = 6
+		return 6
 	case "8":
-		specifiedChannel = 7
+		return 7
 	case "9":
-		specifiedChannel = 8
+		return 8
 	case "10 (Drums)":
-		specifiedChannel = 9
+		return 9
 	case "11":
-		specifiedChannel = 10
+		return 10
 	case "12":
-		specifiedChannel = 11
+		return 11
 	case "13":
-		specifiedChannel = 12
+		return 12
 	case "14":
-		specifiedChannel = 13
+		return 13
 	case "15":
-		specifiedChannel = 14
+		return 14
 	case "16":
-		specifiedChannel = 15
-	}
-
-	logger("generating notes")
-	for i := 0; i < noteCount; {
-		if specifiedChannel == -1 {
-			// user selected "All (Skip Drums)"
-			// set the current channel based the current track number
-			// if the current channel is 9 (drums), skip it
-			currentChannelNumber = trackCount % 16
-			if currentChannelNumber == 9 {
-				currentChannelNumber++ // skip drums
-				trackCount++           // increment track count to avoid double ch 11
-			}
-		} else if specifiedChannel == -2 {
-			// user selected "All"
-			// set the current channel based the current track number
-			currentChannelNumber = trackCount % 16
-		} else {
-			// user selected a specific channel
-			currentChannelNumber = specifiedChannel
-		}
+		return 15
+	}
+	return -1
+}
 
-		// calculate the number of notes to add to the track
-		var nc int
-		if remainingNotes > maxNotesPerTrack {
-			// if there are more notes left than the max notes per track, set the number of notes to the max notes per track
-			// this generates a track with the max notes per track
-			nc = maxNotesPerTrack
-			remainingNotes = remainingNotes - maxNotesPerTrack
-			i = i + maxNotesPerTrack
-		} else {
-			// if there are less notes left, or equal to, the max notes per track, set the remaining notes to 0
-			// this generates a track will all the notes left
-			nc = remainingNotes
-			remainingNotes = 0
-			i = i + noteCount
+// Gets the channel of the next track, from the channel selected in the settings (see parseNoteChannel)
+// When skipping drums, trackCount is incremented past the drum channel
+func trackChannel(specifiedChannel int, trackCount *int) int {
+	if specifiedChannel == -1 {
+		// user selected "All (Skip Drums)"
+		// set the current channel based the current track number
+		// if the current channel is 9 (drums), skip it
+		channel := *trackCount % 16
+		if channel == 9 {
+			channel++     // skip drums
+			*trackCount++ // increment track count to avoid double ch 11
 		}
+		return channel
+	} else if specifiedChannel == -2 {
+		// user selected "All"
+		// set the current channel based the current track number
+		return *trackCount % 16
+	}
 
-		logger("generating track (ch %d) with %d notes | notes left: %d", currentChannelNumber+1, nc, remainingNotes)
+	// user selected a specific channel
+	return specifiedChannel
+}
 
-		track := createTrack(nc, ticks, maxNoteLength, minNoteLength, trimNotes, minVelocity, maxVelocity, uint8(currentChannelNumber))
-		tracks = append(tracks, track)
-		trackCount++
+// Creates notes with a random start, length, key and velocity
+func randomNotes(noteCount int, opts GenOptions, channel uint8) []Note {
+	var notes []Note
+
+	// create notes
+	for i := 0; i < noteCount; i++ {
+		noteStart := randomStart(opts)   // get a random start time between 0 and the length of the midi, or a step of the rhythm
+		noteKey := uint8(rand.Intn(128)) // get a random key between 0 and 127 (C0 - G10)
+
+		// add note
+		notes = append(notes, createNote(noteStart, noteKey, opts, channel))
 	}
 
-	logger("generated %d tracks", len(tracks))
-	return tracks
+	return notes
 }
 
-// Creates a track, with a specified number of notes
-func createTrack(noteCount int, ticks int, maxNoteLength int, minNoteLength int, trimNotes bool, minVelocity int, maxVelocity int, channel uint8) smf.Track {
+// Creates a note at the given start and key, with a random length and velocity
+func createNote(noteStart int, noteKey uint8, opts GenOptions, channel uint8) Note {
 	var (
-		track  smf.Track
-		events []NoteEvent
+		ticks       = opts.Ticks
+		minVelocity = opts.MinVelocity
+		maxVelocity = opts.MaxVelocity
 	)
 
-	// create notes
-	for i := 0; i < noteCount; i++ {
-		noteStart := rand.Intn(ticks)                                          // get a random start time between 0 and the length of the midi
-		noteDuration := rand.Intn(maxNoteLength-minNoteLength) + minNoteLength // get a random duration between min length and the max length of a note
-		noteKey := uint8(rand.Intn(128))                                       // get a random key between 0 and 127 (C0 - G10)
-		noteEnd := noteStart + noteDuration                                    // calculate the end time
-		if trimNotes && noteEnd > ticks {                                      // only cut notes if cutNotes is true
-			noteEnd = ticks // if end time is greater than the length of the midi, set it to the length of the midi
-		}
+	noteDuration := noteLength(opts)       // get a random duration between min length and the max length of a note
+	noteEnd := noteStart + noteDuration    // calculate the end time
+	if opts.TrimNotes && noteEnd > ticks { // only cut notes if cutNotes is true
+		noteEnd = ticks // if end time is greater than the length of the midi, set it to the length of the midi
+	}
 
-		// add note event
-		// notes = append(notes, Notes{uint32(noteStart), uint32(noteEnd), noteKey})
-		events = append(events, NoteEvent{uint32(noteStart), noteKey, true})
-		events = append(events, NoteEvent{uint32(noteEnd), noteKey, false})
+	var noteVelocity int
+	if minVelocity == maxVelocity { // if min and max velocity are the same, set the velocity to that
+		noteVelocity = minVelocity
+	} else {
+		noteVelocity = rand.Intn(maxVelocity-minVelocity) + minVelocity // get a random velocity between min and max
 	}
 
-	// sort notes by start time
-	sort.Sort(EventSorter(events))
+	return Note{Channel: channel, Key: noteKey, Velocity: uint8(noteVelocity), Start: uint32(noteStart), End: uint32(noteEnd)}
+}
+
+// Creates a track with the given notes, each on its own channel, and any controller events sorted by tick
+func buildTrack(notes []Note, controllers ...ControllerEvent) smf.Track {
+	var (
+		track      smf.Track
+		events     []NoteEvent
+		lastTick   uint32 // tick of the last event added
+		controller = 0    // index of the next controller event to add
+	)
+
+	// add note events
+	for _, note := range notes {
+		events = append(events, NoteEvent{note.Start, note.Start, note.Channel, note.Key, note.Velocity, true})
+		events = append(events, NoteEvent{note.End, note.Start, note.Channel, note.Key, 0, false})
+	}
+
+	// sort notes by start time, ending notes before starting new ones on the same tick (see EventSorter)
+	// keeping notes which start together (e.g. chords) in the order they were given
+	sort.Stable(EventSorter(events))
+
+	// adds the controller events up to and including the tick, so they apply to notes starting on the same tick
+	addControllers := func(tick uint32) {
+		for ; controller < len(controllers) && controllers[controller].tick <= tick; controller++ {
+			track.Add(controllers[controller].tick-lastTick, controllers[controller].message)
+			lastTick = controllers[controller].tick
+		}
+	}
 
 	// iterate through notes again
 	for i := 0; i < len(events); i++ {
 		// this is done because the midi library uses a relative tick system to add events
 		// (ticks start from the previous event's end tick)
-		// so we need to calculate the difference between the current note start and the previous note end
+		// so we need to calculate the difference between the current note start and the previous event
 		event := events[i] // get the current note
 
-		var tick uint32
-		if i > 0 { // if this is not the first note
-			prevNote := events[i-1] // get the previous note
-			tick = event.tick - prevNote.tick
-		} else { // if this is the first note
-			tick = event.tick
-		}
+		addControllers(event.tick)
+		tick := event.tick - lastTick
+		lastTick = event.tick
 
 		if event.noteOn { // add note on event
-			var noteVelocity int
-			if minVelocity == maxVelocity { // if min and max velocity are the same, set the velocity to that
-				noteVelocity = minVelocity
-			} else {
-				noteVelocity = rand.Intn(maxVelocity-minVelocity) + minVelocity // get a random velocity between min and max
-			}
-			track.Add(tick, midi.NoteOn(channel, event.key, uint8(noteVelocity)))
+			track.Add(tick, midi.NoteOn(event.channel, event.key, event.velocity))
 		} else { // add note off event
-			track.Add(tick, midi.NoteOff(channel, event.key))
+			track.Add(tick, midi.NoteOff(event.channel, event.key))
 		}
 	}
+	addControllers(^uint32(0))
 	track.Close(0)
 	return track
 }
 
-// Creates a midi file, adding the tracks given
-func createMIDI(midiPath string, ppq int, bpm int, tracks []smf.Track, callback func()) {
+// Creates one or more midi files, adding the parts given (see splitTracks)
+// If there are several parts, each part is written to its own numbered file
+// and a manifest listing the note counts of every part is written next to them
+func createMIDI(midiPath string, ppq int, bpm int, parts [][]smf.Track, logger func(format string, a ...any), callback func()) {
+	if len(parts) == 1 {
+		writeMIDI(midiPath, ppq, bpm, parts[0])
+		callback() // call the callback function
+		return
+	}
+
+	logger("splitting output into %d parts", len(parts))
+
+	manifest := Manifest{}
+	for i, part := range parts {
+		filePath := partPath(midiPath, i+1)
+		writeMIDI(filePath, ppq, bpm, part)
+
+		entry := ManifestPart{
+			File:   filepath.Base(filePath),
+			Tracks: len(part),
+		}
+		for _, track := range part {
+			notes := countTrackNotes(track)
+			entry.TrackNotes = append(entry.TrackNotes, notes)
+			entry.Notes += notes
+		}
+		manifest.Parts = append(manifest.Parts, entry)
+		manifest.TotalNotes += entry.Notes
+
+		logger("saved part %d (%s) with %d tracks and %d notes", i+1, entry.File, entry.Tracks, entry.Notes)
+	}
+
+	writeManifest(manifestPath(midiPath), manifest)
+
+	callback() // call the callback function
+}
+
+// Writes a single midi file with a conductor track, followed by the tracks given
+func writeMIDI(midiPath string, ppq int, bpm int, tracks []smf.Track) {
+	midiData := buildMIDI(ppq, bpm, tracks)
+
+	// write the midi data to the file
+	err := writeFileAtomic(midiPath, func(w io.Writer) error {
+		_, err := midiData.WriteTo(w)
+		return err
+	})
+	handleErr(err)
+}
+
+// Writes a file by writing to a temporary file in the same directory, then renaming it over the target
+// This way the target is either fully replaced or left untouched, never partially written
+func writeFileAtomic(filePath string, write func(w io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	// remove the temporary file if anything goes wrong
+	// after a successful rename this does nothing
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(file.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), filePath)
+}
+
+// Gets the paths of every file createMIDI will write for the given number of parts
+func outputFiles(midiPath string, partCount int) []string {
+	if partCount == 1 {
+		return []string{midiPath}
+	}
+
+	var files []string
+	for i := 1; i <= partCount; i++ {
+		files = append(files, partPath(midiPath, i))
+	}
+	return append(files, manifestPath(midiPath))
+}
+
+// Creates the midi data for a conductor track, followed by the tracks given
+func buildMIDI(ppq int, bpm int, tracks []smf.Track) *smf.SMF {
 	// create vars
 	var (
 		resolution = smf.MetricTicks(ppq)
-		firstTrack smf.Track
 		midiData   = smf.New()
 	)
 
 	// set midi data
 	// ppq, meta track
-	midiData.TimeFormat = resolution                 // set ppq
-	firstTrack.Add(0, smf.MetaTrackSequenceName("")) // add a blank track name
-	firstTrack.Add(0, smf.MetaTempo(float64(bpm)))   // set bpm
-	firstTrack.Close(0)
-	midiData.Add(firstTrack)
+	midiData.TimeFormat = resolution // set ppq
+	midiData.Add(createConductorTrack(bpm))
 
 	// add all tracks provided
 	for i := 0; i < len(tracks); i++ {
 		midiData.Add(tracks[i])
 	}
 
-	// open, or create, the midi file
-	file, err := os.OpenFile(midiPath, os.O_CREATE|os.O_WRONLY, 0644)
-	handleErr(err)
+	return midiData
+}
 
-	// write the midi data to the file
-	_, err = midiData.WriteTo(file)
-	handleErr(err)
+// Creates the meta track which is placed at the start of every midi file
+func createConductorTrack(bpm int) smf.Track {
+	var track smf.Track
+	track.Add(0, smf.MetaTrackSequenceName("")) // add a blank track name
+	track.Add(0, smf.MetaTempo(float64(bpm)))   // set bpm
+	track.Close(0)
+	return track
+}
 
-	// close the file
-	err = file.Close()
-	handleErr(err)
+// A single note, with absolute start and end ticks
+type Note struct {
+	Track    int
+	Channel  uint8
+	Key      uint8
+	Velocity uint8
+	Start    uint32
+	End      uint32
+}
 
-	callback() // call the callback function
+// Gets every note in the midi data, pairing each note on with the next note off of the same channel and key
+// Notes which are never turned off end at the end of their track
+func collectNotes(midiData *smf.SMF) []Note {
+	var notes []Note
+
+	for i, track := range midiData.Tracks {
+		var (
+			tick                   uint32
+			open                   = map[[2]uint8][]int{} // indexes of the notes currently playing, by channel and key
+			channel, key, velocity uint8
+		)
+
+		for _, event := range track {
+			tick += event.Delta
+
+			if event.Message.GetNoteStart(&channel, &key, &velocity) {
+				open[[2]uint8{channel, key}] = append(open[[2]uint8{channel, key}], len(notes))
+				notes = append(notes, Note{i, channel, key, velocity, tick, tick})
+			} else if event.Message.GetNoteEnd(&channel, &key) {
+				playing := open[[2]uint8{channel, key}]
+				if len(playing) > 0 {
+					notes[playing[0]].End = tick
+					open[[2]uint8{channel, key}] = playing[1:]
+				}
+			}
+		}
+
+		for _, playing := range open {
+			for _, index := range playing {
+				notes[index].End = tick
+			}
+		}
+	}
+
+	return notes
 }
 
 type NoteEvent struct {
-	tick   uint32
-	key    uint8
-	noteOn bool
+	tick     uint32
+	start    uint32 // start of the note the event belongs to
+	channel  uint8
+	key      uint8
+	velocity uint8
+	noteOn   bool
 }
 
+// Sorts note events by tick
+// On the same tick, notes which started earlier are turned off first, so a note ending where another one of the
+// same key starts is not cut off by it. Notes with no length are turned off last, after they have been turned on
 type EventSorter []NoteEvent
 
-func (a EventSorter) Len() int           { return len(a) }
-func (a EventSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a EventSorter) Less(i, j int) bool { return a[i].tick < a[j].tick }
+func (a EventSorter) Len() int      { return len(a) }
+func (a EventSorter) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a EventSorter) Less(i, j int) bool {
+	if a[i].tick != a[j].tick {
+		return a[i].tick < a[j].tick
+	}
+	return a[i].order() < a[j].order()
+}
+
+// Gets where the event goes among the events on the same tick
+func (e NoteEvent) order() int {
+	switch {
+	case !e.noteOn && e.start < e.tick: // end of an earlier note
+		return 0
+	case e.noteOn:
+		return 1
+	default: // end of a note with no length
+		return 2
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Lists the note events of a track as "tick on/off key", with absolute ticks
+func trackEvents(track smf.Track) []string {
+	var (
+		events                 []string
+		tick                   uint32
+		channel, key, velocity uint8
+	)
+	for _, event := range track {
+		tick += event.Delta
+		if event.Message.GetNoteStart(&channel, &key, &velocity) {
+			events = append(events, fmt.Sprintf("%d on %d", tick, key))
+		} else if event.Message.GetNoteEnd(&channel, &key) {
+			events = append(events, fmt.Sprintf("%d off %d", tick, key))
+		}
+	}
+	return events
+}
+
+func TestBuildTrackOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes []Note
+		want  []string
+	}{
+		{
+			name:  "note off before note on",
+			notes: []Note{{Key: 60, Velocity: 100, Start: 10, End: 20}, {Key: 60, Velocity: 100, Start: 0, End: 10}},
+			want:  []string{"0 on 60", "10 off 60", "10 on 60", "20 off 60"},
+		},
+		{
+			name:  "other keys",
+			notes: []Note{{Key: 62, Velocity: 100, Start: 10, End: 20}, {Key: 60, Velocity: 100, Start: 0, End: 10}},
+			want:  []string{"0 on 60", "10 off 60", "10 on 62", "20 off 62"},
+		},
+		{
+			name:  "no length",
+			notes: []Note{{Key: 60, Velocity: 100, Start: 10, End: 10}, {Key: 60, Velocity: 100, Start: 0, End: 10}},
+			want:  []string{"0 on 60", "10 off 60", "10 on 60", "10 off 60"},
+		},
+		{
+			name:  "chord keeps its order",
+			notes: []Note{{Key: 64, Velocity: 100, Start: 0, End: 10}, {Key: 60, Velocity: 100, Start: 0, End: 10}, {Key: 67, Velocity: 100, Start: 0, End: 10}},
+			want:  []string{"0 on 64", "0 on 60", "0 on 67", "10 off 64", "10 off 60", "10 off 67"},
+		},
+		{
+			name: "repeated notes",
+			notes: []Note{
+				{Key: 60, Velocity: 100, Start: 20, End: 30},
+				{Key: 60, Velocity: 100, Start: 10, End: 20},
+				{Key: 60, Velocity: 100, Start: 0, End: 10},
+			},
+			want: []string{"0 on 60", "10 off 60", "10 on 60", "20 off 60", "20 on 60", "30 off 60"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := trackEvents(buildTrack(test.notes))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got events %v, want %v", got, test.want)
+			}
+		})
+	}
+}
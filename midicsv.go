@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Names midicsv uses for the text meta events, by meta type
+var midiCSVTextEvents = map[byte]string{
+	0x01: "Text_t",
+	0x02: "Copyright_t",
+	0x03: "Title_t",
+	0x04: "Instrument_name_t",
+	0x05: "Lyric_t",
+	0x06: "Marker_t",
+	0x07: "Cue_point_t",
+}
+
+// Names midicsv uses for the channel events, by status
+var midiCSVChannelEvents = map[byte]string{
+	0x80: "Note_off_c",
+	0x90: "Note_on_c",
+	0xA0: "Poly_aftertouch_c",
+	0xB0: "Control_c",
+	0xC0: "Program_c",
+	0xD0: "Channel_aftertouch_c",
+	0xE0: "Pitch_bend_c",
+}
+
+// Writes the midi data in the midicsv text format, one event per line with absolute times
+// See https://www.fourmilab.ch/webtools/midicsv/ for the format
+func encodeMIDICSV(w io.Writer, midiData *smf.SMF) error {
+	resolution, ok := midiData.TimeFormat.(smf.MetricTicks)
+	if !ok {
+		return errors.New("only midis with a ppq time format are supported")
+	}
+
+	buf := bufio.NewWriter(w)
+	fmt.Fprintf(buf, "0, 0, Header, %d, %d, %d\n", midiData.Format(), len(midiData.Tracks), resolution.Resolution())
+
+	for i, track := range midiData.Tracks {
+		var (
+			number = i + 1 // midicsv numbers tracks from 1
+			tick   uint32
+		)
+
+		fmt.Fprintf(buf, "%d, 0, Start_track\n", number)
+		for _, event := range track {
+			tick += event.Delta
+
+			fields, err := midiCSVFields(event.Message)
+			if err != nil {
+				return fmt.Errorf("track %d, tick %d: %v", number, tick, err)
+			}
+			fmt.Fprintf(buf, "%d, %d, %s\n", number, tick, strings.Join(fields, ", "))
+		}
+
+		// midicsv needs every track to end, even if the midi did not close it
+		if !track.IsClosed() {
+			fmt.Fprintf(buf, "%d, %d, End_track\n", number, tick)
+		}
+	}
+
+	buf.WriteString("0, 0, End_of_file\n")
+	return buf.Flush()
+}
+
+// Gets the event type and values of a message, as written in midicsv
+func midiCSVFields(msg smf.Message) ([]string, error) {
+	if len(msg) == 0 {
+		return nil, errors.New("empty message")
+	}
+
+	switch status := msg[0]; {
+	case status == 0xFF: // meta event
+		if len(msg) < 2 {
+			return nil, errors.New("truncated meta event")
+		}
+		typ := msg[1]
+		data, err := metaData(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		if name, ok := midiCSVTextEvents[typ]; ok {
+			return []string{name, quoteMIDICSV(string(data))}, nil
+		}
+
+		switch {
+		case typ == 0x00 && len(data) == 2:
+			return []string{"Sequence_number", strconv.Itoa(int(data[0])<<8 | int(data[1]))}, nil
+		case typ == 0x20 && len(data) == 1:
+			return []string{"Channel_prefix", strconv.Itoa(int(data[0]))}, nil
+		case typ == 0x21 && len(data) == 1:
+			return []string{"MIDI_port", strconv.Itoa(int(data[0]))}, nil
+		case typ == 0x2F:
+			return []string{"End_track"}, nil
+		case typ == 0x51 && len(data) == 3:
+			return []string{"Tempo", strconv.Itoa(int(data[0])<<16 | int(data[1])<<8 | int(data[2]))}, nil
+		case typ == 0x54 && len(data) == 5:
+			return append([]string{"SMPTE_offset"}, byteFields(data)...), nil
+		case typ == 0x58 && len(data) == 4:
+			return append([]string{"Time_signature"}, byteFields(data)...), nil
+		case typ == 0x59 && len(data) == 2:
+			mode := "\"major\""
+			if data[1] == 1 {
+				mode = "\"minor\""
+			}
+			return []string{"Key_signature", strconv.Itoa(int(int8(data[0]))), mode}, nil
+		case typ == 0x7F:
+			return append([]string{"Sequencer_specific", strconv.Itoa(len(data))}, byteFields(data)...), nil
+		}
+		return append([]string{"Unknown_meta_event", strconv.Itoa(int(typ)), strconv.Itoa(len(data))}, byteFields(data)...), nil
+
+	case status == 0xF0 || status == 0xF7: // sysex, the reader keeps the data without its length
+		name := "System_exclusive"
+		if status == 0xF7 {
+			name = "System_exclusive_packet"
+		}
+		return append([]string{name, strconv.Itoa(len(msg) - 1)}, byteFields(msg[1:])...), nil
+
+	case status >= 0x80 && status < 0xF0: // channel event
+		name := midiCSVChannelEvents[status&0xF0]
+		fields := []string{name, strconv.Itoa(int(status & 0x0F))}
+
+		switch status & 0xF0 {
+		case 0xC0, 0xD0:
+			if len(msg) < 2 {
+				return nil, errors.New("truncated channel event")
+			}
+			return append(fields, strconv.Itoa(int(msg[1]))), nil
+		case 0xE0:
+			if len(msg) < 3 {
+				return nil, errors.New("truncated channel event")
+			}
+			return append(fields, strconv.Itoa(int(msg[1])|int(msg[2])<<7)), nil
+		default:
+			if len(msg) < 3 {
+				return nil, errors.New("truncated channel event")
+			}
+			return append(fields, strconv.Itoa(int(msg[1])), strconv.Itoa(int(msg[2]))), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported message % X", []byte(msg))
+}
+
+// Reads midicsv text back into midi data, the opposite of encodeMIDICSV
+// Events have to be in order within each track, as they are when written by midicsv
+func decodeMIDICSV(r io.Reader) (*smf.SMF, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	reader.LazyQuotes = true
+
+	var (
+		midiData *smf.SMF
+		tracks   = map[int]*smf.Track{}
+		lastTick = map[int]uint32{}
+		numbers  []int // track numbers in the order they started
+	)
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: expected a track, time and event type", line)
+		}
+
+		number, err := strconv.Atoi(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: track is not a number", line)
+		}
+		tick64, err := strconv.ParseUint(record[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: time is not a number", line)
+		}
+		tick := uint32(tick64)
+
+		var (
+			eventType = strings.TrimSpace(record[2])
+			values    = record[3:]
+		)
+
+		switch eventType {
+		case "Header":
+			nums, err := parseNumbers(values, 3)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+
+			switch nums[0] {
+			case 0:
+				midiData = smf.New()
+			case 1:
+				midiData = smf.NewSMF1()
+			case 2:
+				midiData = smf.NewSMF2()
+			default:
+				return nil, fmt.Errorf("line %d: unknown format %d", line, nums[0])
+			}
+			midiData.TimeFormat = smf.MetricTicks(nums[2])
+			continue
+		case "End_of_file":
+			continue
+		case "Start_track":
+			if _, ok := tracks[number]; ok {
+				return nil, fmt.Errorf("line %d: track %d started twice", line, number)
+			}
+			tracks[number] = &smf.Track{}
+			numbers = append(numbers, number)
+			continue
+		}
+
+		track, ok := tracks[number]
+		if !ok {
+			return nil, fmt.Errorf("line %d: track %d has not been started", line, number)
+		}
+		if tick < lastTick[number] {
+			return nil, fmt.Errorf("line %d: time goes backwards", line)
+		}
+		delta := tick - lastTick[number]
+		lastTick[number] = tick
+
+		if eventType == "End_track" {
+			track.Close(delta)
+			continue
+		}
+
+		msg, err := parseMIDICSVEvent(eventType, values)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		track.Add(delta, msg)
+	}
+
+	if midiData == nil {
+		return nil, errors.New("missing the header")
+	}
+
+	for _, number := range numbers {
+		tracks[number].Close(0) // does nothing if the track already ended
+		midiData.Add(*tracks[number])
+	}
+
+	return midiData, nil
+}
+
+// Creates the message for a midicsv event
+func parseMIDICSVEvent(eventType string, values []string) (smf.Message, error) {
+	for typ, name := range midiCSVTextEvents {
+		if name == eventType {
+			if len(values) < 1 {
+				return nil, errors.New("missing the text")
+			}
+			text, err := unquoteMIDICSV(strings.Join(values, ","))
+			if err != nil {
+				return nil, err
+			}
+			return metaMessage(typ, []byte(text)), nil
+		}
+	}
+
+	for status, name := range midiCSVChannelEvents {
+		if name != eventType {
+			continue
+		}
+
+		count := 3
+		if status == 0xC0 || status == 0xD0 || status == 0xE0 {
+			count = 2
+		}
+		nums, err := parseNumbers(values, count)
+		if err != nil {
+			return nil, err
+		}
+		if nums[0] > 15 {
+			return nil, fmt.Errorf("channel %d is not between 0 and 15", nums[0])
+		}
+
+		msg := smf.Message{status | byte(nums[0])}
+		if status == 0xE0 {
+			if nums[1] > 16383 {
+				return nil, fmt.Errorf("pitch bend %d is not between 0 and 16383", nums[1])
+			}
+			return append(msg, byte(nums[1]&0x7F), byte(nums[1]>>7)), nil
+		}
+		for _, num := range nums[1:] {
+			if num > 127 {
+				return nil, fmt.Errorf("%d is not between 0 and 127", num)
+			}
+			msg = append(msg, byte(num))
+		}
+		return msg, nil
+	}
+
+	switch eventType {
+	case "Sequence_number":
+		nums, err := parseNumbers(values, 1)
+		if err != nil {
+			return nil, err
+		}
+		return metaMessage(0x00, []byte{byte(nums[0] >> 8), byte(nums[0])}), nil
+	case "Channel_prefix", "MIDI_port":
+		nums, err := parseNumbers(values, 1)
+		if err != nil {
+			return nil, err
+		}
+		typ := byte(0x20)
+		if eventType == "MIDI_port" {
+			typ = 0x21
+		}
+		return metaMessage(typ, []byte{byte(nums[0])}), nil
+	case "Tempo":
+		nums, err := parseNumbers(values, 1)
+		if err != nil {
+			return nil, err
+		}
+		return metaMessage(0x51, []byte{byte(nums[0] >> 16), byte(nums[0] >> 8), byte(nums[0])}), nil
+	case "SMPTE_offset", "Time_signature":
+		count, typ := 5, byte(0x54)
+		if eventType == "Time_signature" {
+			count, typ = 4, 0x58
+		}
+		nums, err := parseNumbers(values, count)
+		if err != nil {
+			return nil, err
+		}
+		return metaMessage(typ, toBytes(nums)), nil
+	case "Key_signature":
+		if len(values) < 2 {
+			return nil, errors.New("expected a key and a mode")
+		}
+		key, err := strconv.Atoi(strings.TrimSpace(values[0]))
+		if err != nil || key < -7 || key > 7 {
+			return nil, errors.New("key is not between -7 and 7")
+		}
+		mode := byte(0)
+		if strings.Contains(strings.ToLower(values[1]), "minor") {
+			mode = 1
+		}
+		return metaMessage(0x59, []byte{byte(int8(key)), mode}), nil
+	case "Sequencer_specific", "System_exclusive", "System_exclusive_packet":
+		nums, err := parseNumbers(values, 1)
+		if err != nil {
+			return nil, err
+		}
+		data, err := parseNumbers(values[1:], int(nums[0]))
+		if err != nil {
+			return nil, err
+		}
+
+		switch eventType {
+		case "System_exclusive":
+			return append(smf.Message{0xF0}, toBytes(data)...), nil
+		case "System_exclusive_packet":
+			return append(smf.Message{0xF7}, toBytes(data)...), nil
+		}
+		return metaMessage(0x7F, toBytes(data)), nil
+	case "Unknown_meta_event":
+		nums, err := parseNumbers(values, 2)
+		if err != nil {
+			return nil, err
+		}
+		data, err := parseNumbers(values[2:], int(nums[1]))
+		if err != nil {
+			return nil, err
+		}
+		return metaMessage(byte(nums[0]), toBytes(data)), nil
+	}
+
+	return nil, fmt.Errorf("unknown event type %s", eventType)
+}
+
+// Creates a meta message from its type and data
+func metaMessage(typ byte, data []byte) smf.Message {
+	msg := smf.Message{0xFF, typ}
+
+	// the length is a variable length quantity, 7 bits per byte with the high bit set on all but the last
+	length := uint32(len(data))
+	var vlq []byte
+	for {
+		vlq = append([]byte{byte(length & 0x7F)}, vlq...)
+		length >>= 7
+		if length == 0 {
+			break
+		}
+	}
+	for i := 0; i < len(vlq)-1; i++ {
+		vlq[i] |= 0x80
+	}
+
+	msg = append(msg, vlq...)
+	return append(msg, data...)
+}
+
+// Gets the data of a meta message, after its type and length
+func metaData(msg smf.Message) ([]byte, error) {
+	var length uint32
+	for i := 2; i < len(msg); i++ {
+		length = length<<7 | uint32(msg[i]&0x7F)
+		if msg[i]&0x80 == 0 {
+			if uint32(len(msg)-i-1) < length {
+				return nil, errors.New("truncated meta event")
+			}
+			return msg[i+1 : i+1+int(length)], nil
+		}
+	}
+	return nil, errors.New("truncated meta event")
+}
+
+// Parses the first count values as numbers
+func parseNumbers(values []string, count int) ([]uint32, error) {
+	if len(values) < count {
+		return nil, fmt.Errorf("expected %d values, found %d", count, len(values))
+	}
+
+	nums := make([]uint32, count)
+	for i := 0; i < count; i++ {
+		num, err := strconv.ParseUint(strings.TrimSpace(values[i]), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", values[i])
+		}
+		nums[i] = uint32(num)
+	}
+	return nums, nil
+}
+
+func byteFields(data []byte) []string {
+	fields := make([]string, len(data))
+	for i, b := range data {
+		fields[i] = strconv.Itoa(int(b))
+	}
+	return fields
+}
+
+func toBytes(nums []uint32) []byte {
+	data := make([]byte, len(nums))
+	for i, num := range nums {
+		data[i] = byte(num)
+	}
+	return data
+}
+
+// Quotes text the way midicsv does: quotes are doubled, backslashes are escaped, and anything which is not
+// printable ascii is written as a 3 digit octal escape
+func quoteMIDICSV(text string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '"':
+			b.WriteString(`""`)
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c < 0x20 || c > 0x7E:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Undoes the escapes added by quoteMIDICSV, the csv reader has already removed the quotes
+func unquoteMIDICSV(text string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			b.WriteByte(text[i])
+			continue
+		}
+
+		if i+1 < len(text) && text[i+1] == '\\' {
+			b.WriteByte('\\')
+			i++
+			continue
+		}
+		if i+4 <= len(text) {
+			if value, err := strconv.ParseUint(text[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		return "", fmt.Errorf("invalid escape in %q", text)
+	}
+	return b.String(), nil
+}
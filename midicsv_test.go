@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+func TestMIDICSVRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+	}{
+		{"empty track", `0, 0, Header, 1, 1, 960
+1, 0, Start_track
+1, 0, End_track
+0, 0, End_of_file
+`},
+		{"notes", `0, 0, Header, 1, 2, 480
+1, 0, Start_track
+1, 0, Tempo, 500000
+1, 0, Time_signature, 4, 2, 24, 8
+1, 0, End_track
+2, 0, Start_track
+2, 0, Note_on_c, 0, 60, 100
+2, 0, Note_on_c, 9, 36, 127
+2, 480, Note_off_c, 0, 60, 0
+2, 480, Note_on_c, 9, 36, 0
+2, 960, End_track
+0, 0, End_of_file
+`},
+		{"channel events", `0, 0, Header, 0, 1, 96
+1, 0, Start_track
+1, 0, Program_c, 1, 40
+1, 0, Control_c, 1, 7, 100
+1, 10, Pitch_bend_c, 1, 16383
+1, 20, Channel_aftertouch_c, 15, 64
+1, 30, Poly_aftertouch_c, 2, 60, 1
+1, 30, End_track
+0, 0, End_of_file
+`},
+		{"meta events", `0, 0, Header, 1, 1, 960
+1, 0, Start_track
+1, 0, Sequence_number, 258
+1, 0, Title_t, "Filler, with a ""quote"""
+1, 0, Text_t, ""
+1, 0, Copyright_t, "\\ and \012\351"
+1, 0, Channel_prefix, 3
+1, 0, MIDI_port, 1
+1, 0, Key_signature, -3, "minor"
+1, 0, Key_signature, 2, "major"
+1, 0, SMPTE_offset, 96, 0, 0, 0, 0
+1, 0, Sequencer_specific, 3, 0, 1, 2
+1, 0, Unknown_meta_event, 96, 2, 127, 0
+1, 5, Marker_t, "end"
+1, 5, End_track
+0, 0, End_of_file
+`},
+		{"sysex", `0, 0, Header, 1, 1, 960
+1, 0, Start_track
+1, 0, System_exclusive, 3, 65, 16, 247
+1, 0, End_track
+0, 0, End_of_file
+`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			midiData, err := decodeMIDICSV(strings.NewReader(test.csv))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the midi has to survive being written and read again, not just the text
+			var file bytes.Buffer
+			if _, err := midiData.WriteTo(&file); err != nil {
+				t.Fatal(err)
+			}
+			midiData, err = smf.ReadFrom(&file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got bytes.Buffer
+			if err := encodeMIDICSV(&got, midiData); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != test.csv {
+				t.Errorf("got\n%s\nwant\n%s", got.String(), test.csv)
+			}
+		})
+	}
+}
+
+func TestDecodeMIDICSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		err  string
+	}{
+		{"missing header", "1, 0, Start_track\n1, 0, End_track\n", "missing the header"},
+		{"track not started", "0, 0, Header, 1, 1, 960\n1, 0, Note_on_c, 0, 60, 100\n", "line 2: track 1 has not been started"},
+		{"track started twice", "0, 0, Header, 1, 1, 960\n1, 0, Start_track\n1, 0, Start_track\n", "line 3: track 1 started twice"},
+		{"time goes backwards", "0, 0, Header, 1, 1, 960\n1, 0, Start_track\n1, 10, Text_t, \"a\"\n1, 5, End_track\n", "line 4: time goes backwards"},
+		{"channel out of range", "0, 0, Header, 1, 1, 960\n1, 0, Start_track\n1, 0, Note_on_c, 16, 60, 100\n", "line 3: channel 16 is not between 0 and 15"},
+		{"key out of range", "0, 0, Header, 1, 1, 960\n1, 0, Start_track\n1, 0, Note_on_c, 0, 128, 100\n", "line 3: 128 is not between 0 and 127"},
+		{"unknown event", "0, 0, Header, 1, 1, 960\n1, 0, Start_track\n1, 0, Note_maybe_c, 0, 60\n", "line 3: unknown event type Note_maybe_c"},
+		{"unknown format", "0, 0, Header, 3, 1, 960\n", "line 1: unknown format 3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := decodeMIDICSV(strings.NewReader(test.csv))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Note list formats shown in the settings dialog
+const (
+	NoteListNone = "None"
+	NoteListCSV  = "CSV"
+	NoteListJSON = "JSON Lines"
+)
+
+var noteListFormats = []string{NoteListNone, NoteListCSV, NoteListJSON}
+
+// Columns of a csv note list, also used as the keys of a json lines note list
+var noteListColumns = []string{"track", "channel", "start_tick", "end_tick", "key", "velocity", "start_seconds"}
+
+// A single row of a note list
+// Channels are 0 - 15, as they are in the midi data
+type noteRecord struct {
+	Track        int     `json:"track"`
+	Channel      uint8   `json:"channel"`
+	StartTick    uint32  `json:"start_tick"`
+	EndTick      uint32  `json:"end_tick"`
+	Key          uint8   `json:"key"`
+	Velocity     uint8   `json:"velocity"`
+	StartSeconds float64 `json:"start_seconds"`
+}
+
+// A single note of a json note list, with pointers so missing values can be told apart from zeros
+type jsonNoteRecord struct {
+	Track     int     `json:"track"`
+	Channel   uint8   `json:"channel"`
+	StartTick *uint32 `json:"start_tick"`
+	EndTick   *uint32 `json:"end_tick"`
+	Key       *uint8  `json:"key"`
+	Velocity  *uint8  `json:"velocity"`
+}
+
+// Converts the json note to a noteRecord, the same as a csv row
+// The start_tick, end_tick and key are required, velocity is 100 if missing
+func (j jsonNoteRecord) noteRecord(index int) (noteRecord, error) {
+	switch {
+	case j.Key == nil:
+		return noteRecord{}, fmt.Errorf("note %d: missing key", index)
+	case j.StartTick == nil:
+		return noteRecord{}, fmt.Errorf("note %d: missing start_tick", index)
+	case j.EndTick == nil:
+		return noteRecord{}, fmt.Errorf("note %d: missing end_tick", index)
+	}
+
+	record := noteRecord{Track: j.Track, Channel: j.Channel, StartTick: *j.StartTick, EndTick: *j.EndTick, Key: *j.Key, Velocity: 100}
+	if j.Velocity != nil {
+		record.Velocity = *j.Velocity
+	}
+	return record, nil
+}
+
+// Gets the path of the note list written alongside the midi, e.g. output.mid -> output.csv
+func noteListPath(midiPath string, format string) string {
+	ext := ".csv"
+	if format == NoteListJSON {
+		ext = ".jsonl"
+	}
+	return strings.TrimSuffix(midiPath, filepath.Ext(midiPath)) + ext
+}
+
+// Writes every note as a row of a csv file, or a line of a json lines file
+func writeNoteList(w io.Writer, format string, notes []Note, tempos TempoMap) error {
+	buf := bufio.NewWriter(w)
+
+	if format == NoteListJSON {
+		encoder := json.NewEncoder(buf)
+		for _, note := range notes {
+			if err := encoder.Encode(newNoteRecord(note, tempos)); err != nil {
+				return err
+			}
+		}
+		return buf.Flush()
+	}
+
+	writer := csv.NewWriter(buf)
+	writer.Write(noteListColumns)
+	for _, note := range notes {
+		record := newNoteRecord(note, tempos)
+		writer.Write([]string{
+			strconv.Itoa(record.Track),
+			strconv.Itoa(int(record.Channel)),
+			strconv.FormatUint(uint64(record.StartTick), 10),
+			strconv.FormatUint(uint64(record.EndTick), 10),
+			strconv.Itoa(int(record.Key)),
+			strconv.Itoa(int(record.Velocity)),
+			strconv.FormatFloat(record.StartSeconds, 'f', 6, 64),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+func newNoteRecord(note Note, tempos TempoMap) noteRecord {
+	return noteRecord{
+		Track:        note.Track,
+		Channel:      note.Channel,
+		StartTick:    note.Start,
+		EndTick:      note.End,
+		Key:          note.Key,
+		Velocity:     note.Velocity,
+		StartSeconds: tempos.Seconds(note.Start),
+	}
+}
+
+// Reads a note list written by writeNoteList, or by any other program
+// The format is json if the file ends in .json or .jsonl, otherwise csv
+// Json can be one note per line, or an array of notes. Csv needs a header row, using the same columns as
+// writeNoteList, in any order. Only the start_tick, end_tick and key columns are required, velocity is 100 if missing
+func readNoteList(r io.Reader, fileName string) ([]Note, error) {
+	var (
+		records []noteRecord
+		err     error
+	)
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json", ".jsonl":
+		records, err = readNoteListJSON(r)
+	default:
+		records, err = readNoteListCSV(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []Note
+	for i, record := range records {
+		if record.Key > 127 {
+			return nil, fmt.Errorf("note %d: key %d is not between 0 and 127", i+1, record.Key)
+		}
+		if record.Velocity == 0 || record.Velocity > 127 {
+			return nil, fmt.Errorf("note %d: velocity %d is not between 1 and 127", i+1, record.Velocity)
+		}
+		if record.Channel > 15 {
+			return nil, fmt.Errorf("note %d: channel %d is not between 0 and 15", i+1, record.Channel)
+		}
+		if record.EndTick < record.StartTick {
+			return nil, fmt.Errorf("note %d: ends before it starts", i+1)
+		}
+
+		notes = append(notes, Note{record.Track, record.Channel, record.Key, record.Velocity, record.StartTick, record.EndTick})
+	}
+
+	if len(notes) == 0 {
+		return nil, errors.New("the note list is empty")
+	}
+	return notes, nil
+}
+
+func readNoteListJSON(r io.Reader) ([]noteRecord, error) {
+	var (
+		records []noteRecord
+		buf     = bufio.NewReader(r)
+	)
+
+	// check whether this is an array, or one note per line
+	first, err := buf.Peek(1)
+	for err == nil && strings.TrimSpace(string(first)) == "" {
+		buf.ReadByte()
+		first, err = buf.Peek(1)
+	}
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(buf)
+	if first[0] == '[' {
+		var array []jsonNoteRecord
+		if err := decoder.Decode(&array); err != nil {
+			return nil, err
+		}
+		for i, note := range array {
+			record, err := note.noteRecord(i + 1)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	}
+
+	for {
+		var note jsonNoteRecord
+		err := decoder.Decode(&note)
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("note %d: %v", len(records)+1, err)
+		}
+		record, err := note.noteRecord(len(records) + 1)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+func readNoteListCSV(r io.Reader) ([]noteRecord, error) {
+	var records []noteRecord
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// find which column holds which value
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	required := map[string]bool{}
+	for _, name := range []string{"start_tick", "end_tick", "key"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing the %s column", name)
+		}
+		required[name] = true
+	}
+
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		// gets a column as a number, or the fallback if the column is missing
+		// required columns have no fallback, so the row is invalid if they are empty
+		value := func(name string, fallback uint64, bits int) (uint64, error) {
+			i, ok := columns[name]
+			if !ok || i >= len(row) || strings.TrimSpace(row[i]) == "" {
+				if required[name] {
+					return 0, fmt.Errorf("line %d: missing %s", line, name)
+				}
+				return fallback, nil
+			}
+			num, err := strconv.ParseUint(strings.TrimSpace(row[i]), 10, bits)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: %s: not a number", line, name)
+			}
+			return num, nil
+		}
+
+		var (
+			record noteRecord
+			num    uint64
+		)
+		if num, err = value("track", 0, 32); err != nil {
+			return nil, err
+		}
+		record.Track = int(num)
+		if num, err = value("channel", 0, 8); err != nil {
+			return nil, err
+		}
+		record.Channel = uint8(num)
+		if num, err = value("start_tick", 0, 32); err != nil {
+			return nil, err
+		}
+		record.StartTick = uint32(num)
+		if num, err = value("end_tick", 0, 32); err != nil {
+			return nil, err
+		}
+		record.EndTick = uint32(num)
+		if num, err = value("key", 0, 8); err != nil {
+			return nil, err
+		}
+		record.Key = uint8(num)
+		if num, err = value("velocity", 100, 8); err != nil {
+			return nil, err
+		}
+		record.Velocity = uint8(num)
+
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestReadNoteList(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		data     string
+		want     []Note
+		err      string
+	}{
+		{
+			name:     "csv",
+			fileName: "notes.csv",
+			data:     "track,channel,start_tick,end_tick,key,velocity,start_seconds\n0,1,0,10,60,90,0\n2,3,5,20,64,100,0.1\n",
+			want:     []Note{{0, 1, 60, 90, 0, 10}, {2, 3, 64, 100, 5, 20}},
+		},
+		{
+			name:     "csv columns in any order",
+			fileName: "notes.csv",
+			data:     "key, end_tick, start_tick\n60, 10, 0\n",
+			want:     []Note{{0, 0, 60, 100, 0, 10}},
+		},
+		{
+			name:     "csv missing column",
+			fileName: "notes.csv",
+			data:     "start_tick,key\n0,60\n",
+			err:      "missing the end_tick column",
+		},
+		{
+			name:     "csv missing value",
+			fileName: "notes.csv",
+			data:     "start_tick,end_tick,key\n0,10,60\n0,,60\n",
+			err:      "line 3: missing end_tick",
+		},
+		{
+			name:     "csv short row",
+			fileName: "notes.csv",
+			data:     "start_tick,end_tick,key\n0,10\n",
+			err:      "wrong number of fields",
+		},
+		{
+			name:     "csv not a number",
+			fileName: "notes.csv",
+			data:     "start_tick,end_tick,key\n0,10,C4\n",
+			err:      "line 2: key: not a number",
+		},
+		{
+			name:     "json lines",
+			fileName: "notes.jsonl",
+			data:     "{\"track\":1,\"channel\":2,\"start_tick\":0,\"end_tick\":10,\"key\":60,\"velocity\":90}\n{\"start_tick\":5,\"end_tick\":5,\"key\":0}\n",
+			want:     []Note{{1, 2, 60, 90, 0, 10}, {0, 0, 0, 100, 5, 5}},
+		},
+		{
+			name:     "json array",
+			fileName: "notes.json",
+			data:     " [{\"start_tick\":0,\"end_tick\":10,\"key\":60}]",
+			want:     []Note{{0, 0, 60, 100, 0, 10}},
+		},
+		{
+			name:     "json missing key",
+			fileName: "notes.jsonl",
+			data:     "{\"start_tick\":0,\"end_tick\":10,\"key\":60}\n{\"start_tick\":0,\"end_tick\":10}\n",
+			err:      "note 2: missing key",
+		},
+		{
+			name:     "json missing start",
+			fileName: "notes.json",
+			data:     "[{\"end_tick\":10,\"key\":60}]",
+			err:      "note 1: missing start_tick",
+		},
+		{
+			name:     "json missing end",
+			fileName: "notes.jsonl",
+			data:     "{\"start_tick\":0,\"key\":60}",
+			err:      "note 1: missing end_tick",
+		},
+		{
+			name:     "json zero velocity",
+			fileName: "notes.jsonl",
+			data:     "{\"start_tick\":0,\"end_tick\":10,\"key\":60,\"velocity\":0}",
+			err:      "note 1: velocity 0 is not between 1 and 127",
+		},
+		{
+			name:     "key out of range",
+			fileName: "notes.csv",
+			data:     "start_tick,end_tick,key\n0,10,128\n",
+			err:      "note 1: key 128 is not between 0 and 127",
+		},
+		{
+			name:     "channel out of range",
+			fileName: "notes.csv",
+			data:     "channel,start_tick,end_tick,key\n16,0,10,60\n",
+			err:      "note 1: channel 16 is not between 0 and 15",
+		},
+		{
+			name:     "ends before it starts",
+			fileName: "notes.jsonl",
+			data:     "{\"start_tick\":10,\"end_tick\":0,\"key\":60}",
+			err:      "note 1: ends before it starts",
+		},
+		{
+			name:     "empty",
+			fileName: "notes.csv",
+			data:     "start_tick,end_tick,key\n",
+			err:      "the note list is empty",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notes, err := readNoteList(strings.NewReader(test.data), test.fileName)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(notes, test.want) {
+				t.Errorf("got notes %v, want %v", notes, test.want)
+			}
+		})
+	}
+}
+
+func TestNoteListRoundTrip(t *testing.T) {
+	notes := []Note{{0, 0, 60, 100, 0, 960}, {1, 9, 36, 127, 480, 480}, {2, 15, 127, 1, 960, 2000}}
+
+	for _, format := range []string{NoteListCSV, NoteListJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeNoteList(&buf, format, notes, newConstantTempoMap(960, 120)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readNoteList(&buf, noteListPath("output.mid", format))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, notes) {
+				t.Errorf("got notes %v, want %v", got, notes)
+			}
+		})
+	}
+}
+
+func TestCreateTracksFromNotes(t *testing.T) {
+	notes := []Note{
+		{2, 3, 60, 100, 0, 10},
+		{0, 4, 61, 100, 0, 10},
+		{2, 3, 62, 100, 10, 20},
+		{2, 5, 63, 100, 20, 30},
+		{0, 4, 64, 100, 20, 30},
+	}
+
+	tests := []struct {
+		name         string
+		split        string
+		keepTracks   bool
+		keepChannels bool
+		wantTracks   []int     // notes of every track
+		wantChannels [][]uint8 // channels of the notes in every track
+	}{
+		{"split in order", TrackSplitFill, false, false, []int{2, 2, 1}, [][]uint8{{15, 15}, {15, 15}, {15}}},
+		{"keep channels", TrackSplitFill, false, true, []int{2, 2, 1}, [][]uint8{{3, 4}, {3, 5}, {4}}},
+		{"keep tracks", TrackSplitFill, true, false, []int{2, 2, 1}, [][]uint8{{15, 15}, {15, 15}, {15}}},
+		{"keep both", TrackSplitFill, true, true, []int{2, 2, 1}, [][]uint8{{4, 4}, {3, 3}, {5}}},
+		{"track count", TrackSplitCount, false, true, []int{3, 2}, [][]uint8{{3, 3, 4}, {4, 5}}},
+		{"time regions", TrackSplitTime, false, true, []int{2, 2, 1}, [][]uint8{{3, 4}, {3, 5}, {4}}},
+		{"keep tracks ignores the split", TrackSplitCount, true, true, []int{2, 2, 1}, [][]uint8{{4, 4}, {3, 3}, {5}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tracks, err := createTracksFromNotes(notes, GenOptions{MaxNotesPerTrack: 2, NoteChannel: "16", TrackSplit: TrackSplitOptions{Split: test.split, Tracks: 2}}, test.keepTracks, test.keepChannels, t.Logf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				gotTracks   []int
+				gotChannels [][]uint8
+			)
+			for _, track := range tracks {
+				gotTracks = append(gotTracks, countTrackNotes(track))
+			}
+			// the first track of the midi is the conductor track
+			for _, note := range collectNotes(buildMIDI(960, 120, tracks)) {
+				for len(gotChannels) < note.Track {
+					gotChannels = append(gotChannels, nil)
+				}
+				gotChannels[note.Track-1] = append(gotChannels[note.Track-1], note.Channel)
+			}
+			for _, channels := range gotChannels {
+				sort.Slice(channels, func(i, j int) bool { return channels[i] < channels[j] })
+			}
+
+			if !reflect.DeepEqual(gotTracks, test.wantTracks) {
+				t.Errorf("got tracks %v, want %v", gotTracks, test.wantTracks)
+			}
+			if !reflect.DeepEqual(gotChannels, test.wantChannels) {
+				t.Errorf("got channels %v, want %v", gotChannels, test.wantChannels)
+			}
+		})
+	}
+}
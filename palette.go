@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Palettes shown in the settings dialog
+const (
+	PaletteRainbow = "Rainbow"
+	PaletteFire    = "Fire"
+	PaletteOcean   = "Ocean"
+	PalettePastel  = "Pastel"
+	PaletteCustom  = "Custom" // colors typed into the settings dialog
+)
+
+var paletteNames = []string{PaletteRainbow, PaletteFire, PaletteOcean, PalettePastel, PaletteCustom}
+
+// Colors of the built in palettes, the custom palette is parsed from the settings
+var palettes = map[string][]color.RGBA{
+	PaletteRainbow: defaultColors,
+	PaletteFire: {
+		{0x66, 0x00, 0x00, 0xff},
+		{0x99, 0x11, 0x00, 0xff},
+		{0xcc, 0x22, 0x00, 0xff},
+		{0xff, 0x33, 0x00, 0xff},
+		{0xff, 0x66, 0x00, 0xff},
+		{0xff, 0x99, 0x00, 0xff},
+		{0xff, 0xcc, 0x33, 0xff},
+		{0xff, 0xee, 0x99, 0xff},
+	},
+	PaletteOcean: {
+		{0x00, 0x1f, 0x4d, 0xff},
+		{0x00, 0x33, 0x80, 0xff},
+		{0x00, 0x4d, 0xb3, 0xff},
+		{0x00, 0x73, 0xe6, 0xff},
+		{0x00, 0x99, 0xcc, 0xff},
+		{0x00, 0xb3, 0xb3, 0xff},
+		{0x33, 0xcc, 0xb3, 0xff},
+		{0x99, 0xe6, 0xd9, 0xff},
+	},
+	PalettePastel: {
+		{0xff, 0xb3, 0xba, 0xff},
+		{0xff, 0xdf, 0xba, 0xff},
+		{0xff, 0xff, 0xba, 0xff},
+		{0xba, 0xff, 0xc9, 0xff},
+		{0xba, 0xe1, 0xff, 0xff},
+		{0xd5, 0xba, 0xff, 0xff},
+		{0xff, 0xba, 0xf2, 0xff},
+		{0xe0, 0xe0, 0xe0, 0xff},
+	},
+}
+
+// Orders of the tracks or channels, shown in the settings dialog
+const (
+	ColorOrderGenerated = "As Generated"
+	ColorOrderPitch     = "Low to High"   // the lowest tracks or channels, by average key, get the first colors
+	ColorOrderTime      = "Early to Late" // the tracks or channels which start first get the first colors
+)
+
+var colorOrders = []string{ColorOrderGenerated, ColorOrderPitch, ColorOrderTime}
+
+// Palette file formats shown in the settings dialog
+const (
+	PaletteFileNone  = "None"
+	PaletteFileJSON  = "JSON"
+	PaletteFileImage = "Image"
+)
+
+var paletteFileFormats = []string{PaletteFileNone, PaletteFileJSON, PaletteFileImage}
+
+// Settings for coloring the notes, like Black MIDI players color them by track or channel
+type PaletteOptions struct {
+	Colors    []color.RGBA
+	ByChannel bool   // color by channel instead of by track
+	Order     string // how the tracks or channels are ordered, so the colors follow the palette
+	File      string // palette file format written alongside the midi
+}
+
+// Colors in a palette file, by track or channel
+type PaletteFile struct {
+	ColorBy string         `json:"colorBy"` // "track" or "channel"
+	Colors  []PaletteColor `json:"colors"`
+}
+
+// Color of a single track or channel
+// Tracks are counted like in the midi file, where track 0 is the tempo track, and channels are 1 - 16
+type PaletteColor struct {
+	Index int    `json:"index"`
+	Color string `json:"color"`
+}
+
+// Gets the palette colors with the given name, parsing the custom colors if needed
+func paletteColors(name string, custom string) ([]color.RGBA, error) {
+	if colors, ok := palettes[name]; ok {
+		return colors, nil
+	}
+	return parseColors(custom)
+}
+
+// Gets the color of a track or channel, where index is the midi track or the channel (0 - 15)
+func (palette PaletteOptions) color(index int) color.RGBA {
+	if len(palette.Colors) == 0 {
+		return color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+	return palette.Colors[index%len(palette.Colors)]
+}
+
+// Gets the render options which draw the notes with the palette
+func (palette PaletteOptions) renderOptions(width int, height int) RenderOptions {
+	opts := defaultRenderOptions(width, height)
+	opts.ByChannel = palette.ByChannel
+	if len(palette.Colors) > 0 {
+		opts.Colors = palette.Colors
+	}
+	return opts
+}
+
+// Orders the tracks, or the channels of the notes, so the colors of the palette follow the order setting
+// When coloring by channel, the channels in use are given out again in order, and drums are left on channel 10
+func orderTracks(tracks []smf.Track, palette PaletteOptions) []smf.Track {
+	if palette.Order == ColorOrderGenerated || palette.Order == "" {
+		return tracks
+	}
+
+	if palette.ByChannel {
+		return orderChannels(tracks, palette.Order)
+	}
+
+	// tracks without notes go last
+	indexes := make([]int, len(tracks))
+	keys := make([]float64, len(tracks))
+	for i, track := range tracks {
+		indexes[i] = i
+		if keys[i] = trackOrderKey([]smf.Track{track}, palette.Order, -1); keys[i] < 0 {
+			keys[i] = math.Inf(1)
+		}
+	}
+	sort.SliceStable(indexes, func(i, j int) bool { return keys[indexes[i]] < keys[indexes[j]] })
+
+	ordered := make([]smf.Track, len(tracks))
+	for i, index := range indexes {
+		ordered[i] = tracks[index]
+	}
+	return ordered
+}
+
+// Gives out the channels in use again, so the lowest or earliest notes get the lowest channels
+func orderChannels(tracks []smf.Track, order string) []smf.Track {
+	var (
+		used     []int
+		channels [16]float64
+		mapping  [16]uint8
+	)
+	for channel := range mapping {
+		mapping[channel] = uint8(channel)
+	}
+
+	for channel := 0; channel < 16; channel++ {
+		if channel == 9 { // drums keep their channel
+			continue
+		}
+		if key := trackOrderKey(tracks, order, channel); key >= 0 {
+			used = append(used, channel)
+			channels[channel] = key
+		}
+	}
+
+	sorted := append([]int(nil), used...)
+	sort.SliceStable(sorted, func(i, j int) bool { return channels[sorted[i]] < channels[sorted[j]] })
+	for i, channel := range sorted {
+		mapping[channel] = uint8(used[i])
+	}
+
+	ordered := make([]smf.Track, len(tracks))
+	for i, track := range tracks {
+		ordered[i] = make(smf.Track, len(track))
+		for j, event := range track {
+			message := event.Message
+			if len(message) > 0 && message[0] >= 0x80 && message[0] < 0xf0 {
+				message = append(smf.Message(nil), message...)
+				message[0] = message[0]&0xf0 | mapping[message[0]&0x0f]
+			}
+			ordered[i][j] = smf.Event{Delta: event.Delta, Message: message}
+		}
+	}
+	return ordered
+}
+
+// Gets the average key, or first start, of the notes in the tracks on a channel (or any channel if it is -1)
+// Returns -1 if there are no notes
+func trackOrderKey(tracks []smf.Track, order string, channel int) float64 {
+	var (
+		total    float64
+		count    int
+		earliest = -1.0
+	)
+
+	for _, track := range tracks {
+		var (
+			tick              uint32
+			ch, key, velocity uint8
+		)
+		for _, event := range track {
+			tick += event.Delta
+			if !event.Message.GetNoteStart(&ch, &key, &velocity) || (channel >= 0 && int(ch) != channel) {
+				continue
+			}
+			total += float64(key)
+			count++
+			if earliest < 0 || float64(tick) < earliest {
+				earliest = float64(tick)
+			}
+		}
+	}
+
+	if count == 0 {
+		return -1
+	}
+	if order == ColorOrderTime {
+		return earliest
+	}
+	return total / float64(count)
+}
+
+// Gets the path of the palette file written alongside the midi, e.g. output.mid -> output.palette.json
+func paletteFilePath(midiPath string, format string) string {
+	ext := ".palette.json"
+	if format == PaletteFileImage {
+		ext = ".palette.png"
+	}
+	return strings.TrimSuffix(midiPath, filepath.Ext(midiPath)) + ext
+}
+
+// Writes the color of every track (including the tempo track) or channel as a json file, or as an image with a row
+// for every track and a column for every channel, which is how palette images for Black MIDI players are laid out
+func writePaletteFile(w io.Writer, palette PaletteOptions, trackCount int) error {
+	colorAt := func(track int, channel int) color.RGBA {
+		if palette.ByChannel {
+			return palette.color(channel)
+		}
+		return palette.color(track)
+	}
+
+	if palette.File == PaletteFileImage {
+		img := image.NewRGBA(image.Rect(0, 0, 16, trackCount))
+		for track := 0; track < trackCount; track++ {
+			for channel := 0; channel < 16; channel++ {
+				img.SetRGBA(channel, track, colorAt(track, channel))
+			}
+		}
+		return png.Encode(w, img)
+	}
+
+	file := PaletteFile{ColorBy: "track"}
+	if palette.ByChannel {
+		file.ColorBy = "channel"
+		for channel := 0; channel < 16; channel++ {
+			file.Colors = append(file.Colors, PaletteColor{Index: channel + 1, Color: hexColor(colorAt(0, channel))})
+		}
+	} else {
+		for track := 0; track < trackCount; track++ {
+			file.Colors = append(file.Colors, PaletteColor{Index: track, Color: hexColor(colorAt(track, 0))})
+		}
+	}
+
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+)
+
+// Patterns shown in the settings dialog
+const (
+	PatternNone      = "None"
+	PatternSweepUp   = "Sweep Up"
+	PatternSweepDown = "Sweep Down"
+	PatternSine      = "Sine Wave"
+	PatternZigZag    = "Zig-Zag"
+	PatternSpiral    = "Spiral"
+	PatternStaircase = "Staircase"
+)
+
+var patterns = []string{PatternNone, PatternSweepUp, PatternSweepDown, PatternSine, PatternZigZag, PatternSpiral, PatternStaircase}
+
+// number of stairs in each cycle of the staircase pattern
+const staircaseSteps = 8
+
+// Settings for placing notes in a pattern instead of randomly
+type PatternOptions struct {
+	Pattern string
+	Cycles  int // how many times the pattern repeats over the length of the midi, i.e. its speed
+	Width   int // number of keys the pattern covers, centered on the keyboard
+	Density int // number of notes stacked on neighbouring keys at each step of the pattern
+	Mix     int // percent of notes placed in the pattern, the rest are random
+}
+
+// Creates the notes of a track, placing them in the pattern or randomly
+// The pattern is drawn once over every note of every track, so first and noteCount pick the part drawn by this track
+// Pattern notes are spread evenly between random notes, depending on the mix
+func placeNotes(first int, noteCount int, opts GenOptions, channel uint8) []Note {
+	pattern := opts.Pattern
+	if pattern.Pattern == PatternNone || pattern.Pattern == "" || pattern.Mix <= 0 {
+		return freeNotes(noteCount, opts, channel)
+	}
+
+	var (
+		notes     []Note
+		total     = opts.NoteCount * pattern.Mix / 100 // number of pattern notes, out of every note
+		freeCount = 0
+	)
+
+	for i := first; i < first+noteCount; i++ {
+		// note i is in the pattern if it moves the running total of pattern notes up
+		index := (i+1)*pattern.Mix/100 - 1
+		if i*pattern.Mix/100 == index+1 {
+			freeCount++
+			continue
+		}
+
+		start, key := patternPosition(index, total, opts.Ticks, pattern)
+		notes = append(notes, createNote(start, key, opts, channel))
+	}
+
+	return append(notes, freeNotes(freeCount, opts, channel)...)
+}
+
+// Creates notes which are not in the pattern, following a random walk if one is set, or completely random
+// If chords are set, these notes are the roots of the chords
+func freeNotes(noteCount int, opts GenOptions, channel uint8) []Note {
+	roots := func(noteCount int) []Note {
+		if opts.Walk != nil {
+			return walkNotes(noteCount, opts, channel)
+		}
+		return randomNotes(noteCount, opts, channel)
+	}
+
+	if opts.Chords.Chord != ChordNone && opts.Chords.Chord != "" {
+		return chordNotes(noteCount, opts, channel, roots)
+	}
+	return roots(noteCount)
+}
+
+// Gets the start and key of a note in the pattern, out of total notes
+func patternPosition(index int, total int, ticks int, pattern PatternOptions) (int, uint8) {
+	density := pattern.Density
+	if density < 1 {
+		density = 1
+	}
+	width := pattern.Width
+	if width < 1 {
+		width = 1
+	} else if width > 128 {
+		width = 128
+	}
+
+	// notes are placed in steps, with density notes on top of each other at each step
+	var (
+		steps = (total + density - 1) / density
+		step  = index / density
+		layer = index % density
+		t     = float64(step) / float64(steps) // how far through the midi the step is, from 0 to 1
+	)
+
+	// center the pattern, leaving room for the stacked notes above it
+	lowest := (128 - width - density + 1) / 2
+	if lowest < 0 {
+		lowest = 0
+	}
+
+	key := lowest + int(math.Round(patternContour(pattern.Pattern, t, float64(pattern.Cycles), step)*float64(width-1))) + layer
+	if key > 127 {
+		key = 127
+	}
+
+	return int(t * float64(ticks)), uint8(key)
+}
+
+// Gets the height of the pattern, from 0 (lowest key) to 1 (highest key), at t from 0 to 1 through the midi
+// step is the number of the step, which alternates the arms of the spiral
+func patternContour(pattern string, t float64, cycles float64, step int) float64 {
+	if cycles < 1 {
+		cycles = 1
+	}
+	phase := t * cycles
+	cycle := phase - math.Floor(phase) // how far through the current cycle, from 0 to 1
+
+	switch pattern {
+	case PatternSweepUp:
+		return cycle
+	case PatternSweepDown:
+		return 1 - cycle
+	case PatternSine:
+		return 0.5 + 0.5*math.Sin(2*math.Pi*phase)
+	case PatternZigZag:
+		return 1 - math.Abs(2*cycle-1)
+	case PatternStaircase:
+		return math.Floor(cycle*staircaseSteps) / (staircaseSteps - 1)
+	case PatternSpiral:
+		// two arms on opposite sides, winding outwards from the middle over the length of the midi
+		arm := float64(step % 2)
+		return 0.5 + 0.5*t*math.Sin(2*math.Pi*phase+arm*math.Pi)
+	}
+	return 0.5
+}
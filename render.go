@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Settings for drawing notes as a piano roll
+type RenderOptions struct {
+	Width      int
+	Height     int
+	MinKey     uint8        // lowest key shown, at the bottom
+	MaxKey     uint8        // highest key shown, at the top
+	Length     uint32       // number of ticks shown from left to right, 0 to fit every note
+	ByChannel  bool         // color notes by channel instead of by track
+	Colors     []color.RGBA // colors for each track or channel, repeating if there are more tracks than colors
+	Background color.RGBA
+}
+
+// Rainbow of 16 colors, similar to what Black MIDI players use
+var defaultColors = []color.RGBA{
+	{0xff, 0x33, 0x33, 0xff},
+	{0xff, 0x80, 0x33, 0xff},
+	{0xff, 0xcc, 0x33, 0xff},
+	{0xe6, 0xff, 0x33, 0xff},
+	{0x99, 0xff, 0x33, 0xff},
+	{0x4d, 0xff, 0x33, 0xff},
+	{0x33, 0xff, 0x80, 0xff},
+	{0x33, 0xff, 0xcc, 0xff},
+	{0x33, 0xe6, 0xff, 0xff},
+	{0x33, 0x99, 0xff, 0xff},
+	{0x33, 0x4d, 0xff, 0xff},
+	{0x80, 0x33, 0xff, 0xff},
+	{0xcc, 0x33, 0xff, 0xff},
+	{0xff, 0x33, 0xe6, 0xff},
+	{0xff, 0x33, 0x99, 0xff},
+	{0xff, 0x33, 0x4d, 0xff},
+}
+
+// Gets the default render options, showing every key
+func defaultRenderOptions(width int, height int) RenderOptions {
+	return RenderOptions{
+		Width:      width,
+		Height:     height,
+		MinKey:     0,
+		MaxKey:     127,
+		Colors:     defaultColors,
+		Background: color.RGBA{0x10, 0x10, 0x10, 0xff},
+	}
+}
+
+// Gets the color a note is drawn with
+func (opts RenderOptions) noteColor(note Note) color.RGBA {
+	if len(opts.Colors) == 0 {
+		return color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+
+	index := note.Track
+	if opts.ByChannel {
+		index = int(note.Channel)
+	}
+	return opts.Colors[index%len(opts.Colors)]
+}
+
+// Draws the notes as a piano roll, with time from left to right and keys from bottom to top
+// Notes are drawn in order, so later tracks are drawn on top of earlier ones
+func drawPianoRoll(notes []Note, opts RenderOptions) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{opts.Background}, image.Point{}, draw.Src)
+
+	layoutPianoRoll(notes, opts, func(note Note, rect image.Rectangle) {
+		fill := opts.noteColor(note)
+		draw.Draw(img, rect, &image.Uniform{fill}, image.Point{}, draw.Src)
+
+		// darken the start of the note, so notes next to each other can be told apart
+		if rect.Dx() > 2 {
+			edge := color.RGBA{fill.R / 2, fill.G / 2, fill.B / 2, fill.A}
+			draw.Draw(img, image.Rect(rect.Min.X, rect.Min.Y, rect.Min.X+1, rect.Max.Y), &image.Uniform{edge}, image.Point{}, draw.Src)
+		}
+	})
+
+	return img
+}
+
+// Writes the piano roll as a png image
+func encodePianoRollPNG(w io.Writer, notes []Note, opts RenderOptions) error {
+	return png.Encode(w, drawPianoRoll(notes, opts))
+}
+
+// Writes the piano roll as an svg image, with a rectangle for every note
+func encodePianoRollSVG(w io.Writer, notes []Note, opts RenderOptions) error {
+	buf := bufio.NewWriter(w)
+
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", opts.Width, opts.Height, opts.Width, opts.Height)
+	fmt.Fprintf(buf, `<rect width="%d" height="%d" fill="%s"/>`+"\n", opts.Width, opts.Height, hexColor(opts.Background))
+
+	layoutPianoRoll(notes, opts, func(note Note, rect image.Rectangle) {
+		fmt.Fprintf(buf, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy(), hexColor(opts.noteColor(note)))
+	})
+
+	buf.WriteString("</svg>\n")
+	return buf.Flush()
+}
+
+// Works out where each note goes in the piano roll, calling place with the pixels it covers
+// Notes outside of the key range or length are skipped
+func layoutPianoRoll(notes []Note, opts RenderOptions, place func(note Note, rect image.Rectangle)) {
+	if opts.MaxKey < opts.MinKey || opts.Width <= 0 || opts.Height <= 0 {
+		return
+	}
+
+	length := opts.Length
+	if length == 0 {
+		for _, note := range notes {
+			if note.End > length {
+				length = note.End
+			}
+		}
+	}
+	if length == 0 {
+		return
+	}
+
+	var (
+		keys      = float64(opts.MaxKey) - float64(opts.MinKey) + 1
+		keyHeight = float64(opts.Height) / keys
+		tickWidth = float64(opts.Width) / float64(length)
+	)
+
+	for _, note := range notes {
+		if note.Key < opts.MinKey || note.Key > opts.MaxKey || note.Start >= length {
+			continue
+		}
+
+		// every note is at least 1 pixel wide and tall, so short notes are still visible
+		x0 := int(float64(note.Start) * tickWidth)
+		x1 := int(float64(note.End) * tickWidth)
+		if x1 <= x0 {
+			x1 = x0 + 1
+		}
+
+		row := float64(opts.MaxKey - note.Key)
+		y0 := int(row * keyHeight)
+		y1 := int((row + 1) * keyHeight)
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		place(note, image.Rect(x0, y0, x1, y1))
+	}
+}
+
+// Formats a color as #rrggbb
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// Parses a comma separated list of #rrggbb colors
+func parseColors(text string) ([]color.RGBA, error) {
+	var colors []color.RGBA
+
+	for _, field := range strings.Split(text, ",") {
+		field = strings.TrimPrefix(strings.TrimSpace(field), "#")
+		if field == "" {
+			continue
+		}
+
+		value, err := strconv.ParseUint(field, 16, 32)
+		if err != nil || len(field) != 6 {
+			return nil, fmt.Errorf("%q is not a #rrggbb color", field)
+		}
+		colors = append(colors, color.RGBA{uint8(value >> 16), uint8(value >> 8), uint8(value), 0xff})
+	}
+
+	if len(colors) == 0 {
+		return nil, errors.New("no colors given")
+	}
+	return colors, nil
+}
+
+// Formats colors as a comma separated list, the opposite of parseColors
+func formatColors(colors []color.RGBA) string {
+	var fields []string
+	for _, c := range colors {
+		fields = append(fields, hexColor(c))
+	}
+	return strings.Join(fields, ", ")
+}
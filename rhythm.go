@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Rhythms shown in the settings dialog
+const (
+	RhythmNone      = "None"
+	RhythmEuclidean = "Euclidean"
+	RhythmSteps     = "Step Pattern"
+)
+
+var rhythms = []string{RhythmNone, RhythmEuclidean, RhythmSteps}
+
+// Settings for starting notes on the steps of a rhythm, instead of on any tick
+// Each pattern is one bar (4 beats) long, and its steps are spread evenly over the bar
+type RhythmOptions struct {
+	Rhythm  string
+	Pulses  int    // number of hits in the euclidean rhythm, k in E(k,n)
+	Steps   int    // number of steps in the euclidean rhythm, n in E(k,n)
+	Pattern string // step patterns for the bars, e.g. "x..x..x. | x.x.x.x.", used in turn for every bar
+
+	onsets []int // every tick a note can start on, filled in by createTracks
+}
+
+// Gets the patterns of the rhythm, one for each bar in turn, with true for a hit and false for a rest
+func (rhythm RhythmOptions) bars() ([][]bool, error) {
+	switch rhythm.Rhythm {
+	case RhythmEuclidean:
+		if rhythm.Steps < 1 || rhythm.Pulses < 1 || rhythm.Pulses > rhythm.Steps {
+			return nil, fmt.Errorf("E(%d,%d) needs at least 1 pulse, and no more pulses than steps", rhythm.Pulses, rhythm.Steps)
+		}
+		return [][]bool{euclideanRhythm(rhythm.Pulses, rhythm.Steps)}, nil
+	case RhythmSteps:
+		return parseStepPatterns(rhythm.Pattern)
+	}
+	return nil, nil
+}
+
+// Spreads k hits as evenly as possible over n steps, e.g. E(3,8) is x..x..x. and E(5,8) is x.xx.xx.
+// Uses Bjorklund's algorithm: starting with k hits and n-k rests, the leftover groups are appended to the
+// groups in front of them until at most one group is left over
+func euclideanRhythm(k int, n int) []bool {
+	var front, back [][]bool
+	for i := 0; i < n; i++ {
+		if i < k {
+			front = append(front, []bool{true})
+		} else {
+			back = append(back, []bool{false})
+		}
+	}
+
+	for len(back) > 1 {
+		pairs := len(front)
+		if len(back) < pairs {
+			pairs = len(back)
+		}
+
+		var joined [][]bool
+		for i := 0; i < pairs; i++ {
+			joined = append(joined, append(front[i], back[i]...))
+		}
+
+		// whichever groups were not paired up are left over for the next round
+		if len(front) > pairs {
+			back = front[pairs:]
+		} else {
+			back = back[pairs:]
+		}
+		front = joined
+	}
+
+	var steps []bool
+	for _, group := range append(front, back...) {
+		steps = append(steps, group...)
+	}
+	return steps
+}
+
+// Parses step patterns like "x..x..x. | x.x.x.x.", with x for a hit and . for a rest, and | between bars
+// 1 can also be used for a hit, and -, _ or 0 for a rest
+func parseStepPatterns(text string) ([][]bool, error) {
+	var (
+		bars [][]bool
+		hits int
+	)
+
+	for i, field := range strings.Split(text, "|") {
+		var steps []bool
+		for _, char := range field {
+			switch char {
+			case 'x', 'X', '1':
+				steps = append(steps, true)
+				hits++
+			case '.', '-', '_', '0':
+				steps = append(steps, false)
+			case ' ', '\t':
+			default:
+				return nil, fmt.Errorf("bar %d: %q is not a step, use x for a hit and . for a rest", i+1, char)
+			}
+		}
+		if len(steps) > 0 {
+			bars = append(bars, steps)
+		}
+	}
+
+	if hits == 0 {
+		return nil, errors.New("the step pattern has no hits")
+	}
+	return bars, nil
+}
+
+// Gets every tick a note can start on, by repeating the patterns over the length of the midi
+func rhythmOnsets(rhythm RhythmOptions, ticks int, ppq int) ([]int, error) {
+	bars, err := rhythm.bars()
+	if err != nil || len(bars) == 0 {
+		return nil, err
+	}
+
+	if ppq < 1 {
+		return nil, errors.New("the rhythm needs the ppq of the midi")
+	}
+
+	var (
+		onsets    []int
+		barLength = ppq * 4
+	)
+	for bar := 0; bar*barLength < ticks; bar++ {
+		steps := bars[bar%len(bars)]
+		for i, hit := range steps {
+			tick := bar*barLength + i*barLength/len(steps)
+			if hit && tick < ticks {
+				onsets = append(onsets, tick)
+			}
+		}
+	}
+
+	if len(onsets) == 0 {
+		return nil, errors.New("the rhythm has no hits inside the length of the midi")
+	}
+	return onsets, nil
+}
+
+// Gets a random start for a note, on a step of the rhythm if there is one
+// When there are more notes than steps, notes on the same step stack into chords
+func randomStart(opts GenOptions) int {
+	if onsets := opts.Rhythm.onsets; len(onsets) > 0 {
+		return onsets[rand.Intn(len(onsets))]
+	}
+	return rand.Intn(opts.Ticks)
+}
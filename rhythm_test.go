@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Writes a rhythm as x for a hit and . for a rest
+func formatSteps(steps []bool) string {
+	var b strings.Builder
+	for _, hit := range steps {
+		if hit {
+			b.WriteByte('x')
+		} else {
+			b.WriteByte('.')
+		}
+	}
+	return b.String()
+}
+
+func TestEuclideanRhythm(t *testing.T) {
+	tests := []struct {
+		k, n int
+		want string
+	}{
+		{1, 1, "x"},
+		{1, 4, "x..."},
+		{2, 5, "x.x.."},
+		{3, 4, "xxx."},
+		{3, 8, "x..x..x."},
+		{4, 4, "xxxx"},
+		{4, 12, "x..x..x..x.."},
+		{7, 12, "x.xx.x.xx.x."},
+		{5, 8, "x.xx.xx."},
+		{5, 16, "x..x..x..x..x..."},
+		{7, 16, "x..x.x.x..x.x.x."},
+	}
+
+	for _, test := range tests {
+		got := euclideanRhythm(test.k, test.n)
+		if formatSteps(got) != test.want {
+			t.Errorf("E(%d,%d) = %s, want %s", test.k, test.n, formatSteps(got), test.want)
+		}
+
+		hits := strings.Count(formatSteps(got), "x")
+		if hits != test.k {
+			t.Errorf("E(%d,%d) has %d hits, want %d", test.k, test.n, hits, test.k)
+		}
+	}
+}
+
+func TestRhythmOnsets(t *testing.T) {
+	tests := []struct {
+		name   string
+		rhythm RhythmOptions
+		ticks  int
+		want   []int
+		err    string
+	}{
+		{"none", RhythmOptions{Rhythm: RhythmNone}, 960, nil, ""},
+		{"euclidean", RhythmOptions{Rhythm: RhythmEuclidean, Pulses: 3, Steps: 8}, 16, []int{0, 6, 12}, ""},
+		{"repeats every bar", RhythmOptions{Rhythm: RhythmEuclidean, Pulses: 1, Steps: 4}, 40, []int{0, 16, 32}, ""},
+		{"too many pulses", RhythmOptions{Rhythm: RhythmEuclidean, Pulses: 5, Steps: 4}, 16, nil, "E(5,4)"},
+		{"no pulses", RhythmOptions{Rhythm: RhythmEuclidean, Pulses: 0, Steps: 4}, 16, nil, "E(0,4)"},
+		{"steps", RhythmOptions{Rhythm: RhythmSteps, Pattern: "x..x | .x.x"}, 32, []int{0, 12, 20, 28}, ""},
+		{"steps cut at the length", RhythmOptions{Rhythm: RhythmSteps, Pattern: "x.x."}, 10, []int{0, 8}, ""},
+		{"no hits", RhythmOptions{Rhythm: RhythmSteps, Pattern: "...."}, 16, nil, "no hits"},
+		{"bad step", RhythmOptions{Rhythm: RhythmSteps, Pattern: "x.o."}, 16, nil, "bar 1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// a ppq of 4 makes each bar 16 ticks
+			got, err := rhythmOnsets(test.rhythm, test.ticks, 4)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got error %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got onsets %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Split modes shown in the settings dialog
+const (
+	SplitNone   = "None"
+	SplitTracks = "Max Tracks"
+	SplitNotes  = "Max Notes"
+	SplitSize   = "Max Size (MB)"
+)
+
+var splitModes = []string{SplitNone, SplitTracks, SplitNotes, SplitSize}
+
+// Describes how the generated tracks are divided between output files
+// Limit is the max number of tracks, notes, or megabytes per file, depending on the mode
+type SplitPolicy struct {
+	Mode  string
+	Limit int
+}
+
+// Lists what was written to each part of a split output
+type Manifest struct {
+	TotalNotes int            `json:"totalNotes"`
+	Parts      []ManifestPart `json:"parts"`
+}
+
+type ManifestPart struct {
+	File       string `json:"file"`
+	Tracks     int    `json:"tracks"`
+	Notes      int    `json:"notes"`
+	TrackNotes []int  `json:"trackNotes"`
+}
+
+// Divides the tracks into parts according to the policy
+// Tracks are never split themselves, so a part always contains at least one track even if that goes over the limit
+func splitTracks(tracks []smf.Track, policy SplitPolicy) [][]smf.Track {
+	if policy.Mode == SplitNone || policy.Mode == "" || policy.Limit <= 0 {
+		return [][]smf.Track{tracks}
+	}
+
+	var (
+		parts   [][]smf.Track
+		current []smf.Track
+		used    int64
+		limit   = int64(policy.Limit)
+	)
+
+	if policy.Mode == SplitSize {
+		// the limit is in megabytes, every part also has a header and a conductor track
+		limit = limit*1024*1024 - headerSize - trackSize(createConductorTrack(0))
+	}
+
+	for _, track := range tracks {
+		var cost int64
+		switch policy.Mode {
+		case SplitTracks:
+			cost = 1
+		case SplitNotes:
+			cost = int64(countTrackNotes(track))
+		case SplitSize:
+			cost = trackSize(track)
+		}
+
+		// start a new part if this track would go over the limit
+		if len(current) > 0 && used+cost > limit {
+			parts = append(parts, current)
+			current = nil
+			used = 0
+		}
+
+		current = append(current, track)
+		used += cost
+	}
+
+	if len(current) > 0 || len(parts) == 0 {
+		parts = append(parts, current)
+	}
+
+	return parts
+}
+
+// Counts the notes in a track, a note being a note on with a velocity above 0
+func countTrackNotes(track smf.Track) int {
+	var (
+		count                  int
+		channel, key, velocity uint8
+	)
+
+	for _, event := range track {
+		if event.Message.GetNoteStart(&channel, &key, &velocity) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Size of the MThd chunk at the start of every midi file
+const headerSize = 14
+
+// Calculates how many bytes a track takes up once written, including its chunk header
+func trackSize(track smf.Track) int64 {
+	midiData := smf.New()
+	midiData.Add(track)
+
+	counter := &countingWriter{}
+	_, err := midiData.WriteTo(counter)
+	handleErr(err)
+
+	return counter.n - headerSize
+}
+
+// Writer that discards everything written to it, only keeping count of the bytes
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// Gets the path of a numbered part, e.g. output.mid -> output_001.mid
+func partPath(midiPath string, part int) string {
+	ext := filepath.Ext(midiPath)
+	return fmt.Sprintf("%s_%03d%s", strings.TrimSuffix(midiPath, ext), part, ext)
+}
+
+// Gets the path of the manifest written alongside the parts, e.g. output.mid -> output_manifest.json
+func manifestPath(midiPath string) string {
+	return strings.TrimSuffix(midiPath, filepath.Ext(midiPath)) + "_manifest.json"
+}
+
+// Writes the manifest as indented json
+func writeManifest(filePath string, manifest Manifest) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	handleErr(err)
+
+	err = writeFileAtomic(filePath, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+	handleErr(err)
+}
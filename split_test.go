@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Creates a track with the given number of notes, one after another
+func testTrack(notes int) smf.Track {
+	var track smf.Track
+	for i := 0; i < notes; i++ {
+		track.Add(0, midi.NoteOn(0, 60, 100))
+		track.Add(10, midi.NoteOff(0, 60))
+	}
+	track.Close(0)
+	return track
+}
+
+// Creates a track for each of the note counts given
+func testTracks(noteCounts ...int) []smf.Track {
+	var tracks []smf.Track
+	for _, notes := range noteCounts {
+		tracks = append(tracks, testTrack(notes))
+	}
+	return tracks
+}
+
+func TestSplitTracks(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []smf.Track
+		policy SplitPolicy
+		want   [][]int // notes of every track in each part
+	}{
+		{"none", testTracks(5, 3, 4, 1), SplitPolicy{SplitNone, 1}, [][]int{{5, 3, 4, 1}}},
+		{"no limit", testTracks(5, 3, 4, 1), SplitPolicy{SplitTracks, 0}, [][]int{{5, 3, 4, 1}}},
+		{"max tracks", testTracks(5, 3, 4, 1), SplitPolicy{SplitTracks, 3}, [][]int{{5, 3, 4}, {1}}},
+		{"max tracks exact", testTracks(5, 3, 4, 1), SplitPolicy{SplitTracks, 2}, [][]int{{5, 3}, {4, 1}}},
+		{"max notes", testTracks(5, 3, 4, 1), SplitPolicy{SplitNotes, 8}, [][]int{{5, 3}, {4, 1}}},
+		{"track over the limit", testTracks(5, 3, 4, 1), SplitPolicy{SplitNotes, 2}, [][]int{{5}, {3}, {4}, {1}}},
+		{"under the size", testTracks(5, 3, 4, 1), SplitPolicy{SplitSize, 1}, [][]int{{5, 3, 4, 1}}},
+		{"over the size", testTracks(100000, 100000, 10), SplitPolicy{SplitSize, 1}, [][]int{{100000}, {100000, 10}}},
+		{"no tracks", nil, SplitPolicy{SplitTracks, 2}, [][]int{nil}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got [][]int
+			for _, part := range splitTracks(test.tracks, test.policy) {
+				var notes []int
+				for _, track := range part {
+					notes = append(notes, countTrackNotes(track))
+				}
+				got = append(got, notes)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got parts %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSplitTracksSizeLimit(t *testing.T) {
+	limit := int64(1024 * 1024)
+	for i, part := range splitTracks(testTracks(60000, 60000, 60000, 10), SplitPolicy{SplitSize, 1}) {
+		size := headerSize + trackSize(createConductorTrack(0))
+		for _, track := range part {
+			size += trackSize(track)
+		}
+		if size > limit {
+			t.Errorf("part %d is %d bytes, over the limit of %d", i+1, size, limit)
+		}
+	}
+}
+
+func TestCreateMIDIManifest(t *testing.T) {
+	var (
+		dir      = t.TempDir()
+		midiPath = filepath.Join(dir, "output.mid")
+		tracks   = testTracks(5, 3, 4, 1)
+	)
+
+	createMIDI(midiPath, 960, 120, splitTracks(tracks, SplitPolicy{SplitNotes, 8}), t.Logf, func() {})
+
+	data, err := os.ReadFile(manifestPath(midiPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	want := Manifest{
+		TotalNotes: 13,
+		Parts: []ManifestPart{
+			{File: "output_001.mid", Tracks: 2, Notes: 8, TrackNotes: []int{5, 3}},
+			{File: "output_002.mid", Tracks: 2, Notes: 5, TrackNotes: []int{4, 1}},
+		},
+	}
+	if !reflect.DeepEqual(manifest, want) {
+		t.Errorf("got manifest %+v, want %+v", manifest, want)
+	}
+
+	// every part has its own conductor track before the tracks listed
+	for _, part := range want.Parts {
+		midiData, err := smf.ReadFile(filepath.Join(dir, part.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(midiData.Tracks) != part.Tracks+1 {
+			t.Errorf("%s has %d tracks, want %d", part.File, len(midiData.Tracks), part.Tracks+1)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Statistics about the notes in a midi
+type Stats struct {
+	TotalNotes        int            `json:"totalNotes"`
+	TrackNotes        []int          `json:"trackNotes"`
+	ChannelNotes      [16]int        `json:"channelNotes"`
+	KeyHistogram      [128]int       `json:"keyHistogram"`
+	VelocityHistogram [128]int       `json:"velocityHistogram"`
+	LengthHistogram   []LengthBucket `json:"lengthHistogram"`
+	PeakNPS           int            `json:"peakNps"`
+	AverageNPS        float64        `json:"averageNps"`
+	MaxPolyphony      int            `json:"maxPolyphony"`
+	AveragePolyphony  float64        `json:"averagePolyphony"`
+	Duration          float64        `json:"durationSeconds"`
+}
+
+// Number of notes with a length, in ticks, between Min and Max (inclusive)
+type LengthBucket struct {
+	Min   uint32 `json:"min"`
+	Max   uint32 `json:"max"`
+	Count int    `json:"count"`
+}
+
+// Calculates statistics for all notes in the midi data
+func analyzeMIDI(midiData *smf.SMF) (Stats, error) {
+	tempos, err := newTempoMap(midiData)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	var (
+		stats   = Stats{TrackNotes: make([]int, len(midiData.Tracks))}
+		notes   = collectNotes(midiData)
+		lengths = map[int]int{} // note count by length bucket
+		lastEnd uint32
+	)
+
+	for _, note := range notes {
+		stats.TotalNotes++
+		stats.TrackNotes[note.Track]++
+		stats.ChannelNotes[note.Channel]++
+		stats.KeyHistogram[note.Key]++
+		stats.VelocityHistogram[note.Velocity]++
+		lengths[bits.Len32(note.End-note.Start)]++
+
+		if note.End > lastEnd {
+			lastEnd = note.End
+		}
+	}
+
+	// lengths are bucketed by powers of two: 0, 1, 2-3, 4-7...
+	var buckets []int
+	for bucket := range lengths {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+	for _, bucket := range buckets {
+		min, max := uint32(0), uint32(0)
+		if bucket > 0 {
+			min = 1 << (bucket - 1)
+			max = min*2 - 1
+		}
+		stats.LengthHistogram = append(stats.LengthHistogram, LengthBucket{min, max, lengths[bucket]})
+	}
+
+	stats.Duration = tempos.Seconds(lastEnd)
+
+	if stats.Duration > 0 {
+		stats.AverageNPS = float64(stats.TotalNotes) / stats.Duration
+	}
+
+	// notes per second, counting the notes which start in each second
+	perSecond := map[int]int{}
+	for _, note := range notes {
+		second := int(tempos.Seconds(note.Start))
+		perSecond[second]++
+		if perSecond[second] > stats.PeakNPS {
+			stats.PeakNPS = perSecond[second]
+		}
+	}
+
+	stats.MaxPolyphony, stats.AveragePolyphony = polyphony(notes, lastEnd)
+
+	return stats, nil
+}
+
+// Calculates the most notes playing at once, and the average number of notes playing over the length given
+func polyphony(notes []Note, length uint32) (int, float64) {
+	type change struct {
+		tick  uint32
+		delta int
+	}
+
+	var changes []change
+	for _, note := range notes {
+		changes = append(changes, change{note.Start, 1}, change{note.End, -1})
+	}
+
+	// at the same tick, notes ending come before notes starting
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].tick != changes[j].tick {
+			return changes[i].tick < changes[j].tick
+		}
+		return changes[i].delta < changes[j].delta
+	})
+
+	var (
+		playing, max int
+		area         float64 // sum of notes playing * ticks
+		lastTick     uint32
+	)
+
+	for _, c := range changes {
+		area += float64(playing) * float64(c.tick-lastTick)
+		lastTick = c.tick
+
+		playing += c.delta
+		if playing > max {
+			max = playing
+		}
+	}
+
+	if length == 0 {
+		return max, 0
+	}
+	return max, area / float64(length)
+}
+
+// Formats the statistics as text for the statistics tab
+func formatStats(stats Stats) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "total notes: %d\n", stats.TotalNotes)
+	fmt.Fprintf(&b, "duration: %.2fs\n", stats.Duration)
+	fmt.Fprintf(&b, "nps: %d peak | %.2f average\n", stats.PeakNPS, stats.AverageNPS)
+	fmt.Fprintf(&b, "polyphony: %d max | %.2f average\n", stats.MaxPolyphony, stats.AveragePolyphony)
+
+	b.WriteString("\nnotes per track\n")
+	for i, count := range stats.TrackNotes {
+		fmt.Fprintf(&b, "  track %d: %d\n", i, count)
+	}
+
+	b.WriteString("\nnotes per channel\n")
+	for i, count := range stats.ChannelNotes {
+		if count > 0 {
+			fmt.Fprintf(&b, "  ch %d: %d\n", i+1, count)
+		}
+	}
+
+	// keys are grouped by octave, and velocities in groups of 16, to keep the text short
+	var octaves [11]int
+	for key, count := range stats.KeyHistogram {
+		octaves[key/12] += count
+	}
+	b.WriteString("\nkeys\n")
+	for i, count := range octaves {
+		last := i*12 + 11
+		if last > 127 {
+			last = 127
+		}
+		writeBar(&b, fmt.Sprintf("%d-%d", i*12, last), count, stats.TotalNotes)
+	}
+
+	var velocities [8]int
+	for velocity, count := range stats.VelocityHistogram {
+		velocities[velocity/16] += count
+	}
+	b.WriteString("\nvelocities\n")
+	for i, count := range velocities {
+		writeBar(&b, fmt.Sprintf("%d-%d", i*16, i*16+15), count, stats.TotalNotes)
+	}
+
+	b.WriteString("\nnote lengths (ticks)\n")
+	for _, bucket := range stats.LengthHistogram {
+		writeBar(&b, fmt.Sprintf("%d-%d", bucket.Min, bucket.Max), bucket.Count, stats.TotalNotes)
+	}
+
+	return b.String()
+}
+
+// Writes a single histogram row, with a bar scaled to the total
+func writeBar(b *strings.Builder, label string, count int, total int) {
+	width := 0
+	if total > 0 {
+		width = int(math.Round(float64(count) / float64(total) * 40))
+	}
+	fmt.Fprintf(b, "  %-12s %-40s %d\n", label, strings.Repeat("#", width), count)
+}
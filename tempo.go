@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"sort"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Converts ticks to seconds, following the tempo changes of a midi
+type TempoMap struct {
+	ppq     float64
+	changes []tempoChange
+}
+
+type tempoChange struct {
+	tick    uint32
+	bpm     float64
+	seconds float64 // time at which the change happens
+}
+
+// Creates a tempo map from the tempo events in every track of the midi data
+// Midis without any tempo events play at 120 bpm
+func newTempoMap(midiData *smf.SMF) (TempoMap, error) {
+	resolution, ok := midiData.TimeFormat.(smf.MetricTicks)
+	if !ok {
+		return TempoMap{}, errors.New("only midis with a ppq time format are supported")
+	}
+
+	tempos := TempoMap{ppq: float64(resolution.Resolution())}
+	changes := []tempoChange{{0, 120, 0}}
+
+	for _, track := range midiData.Tracks {
+		var (
+			tick uint32
+			bpm  float64
+		)
+
+		for _, event := range track {
+			tick += event.Delta
+			if event.Message.GetMetaTempo(&bpm) && bpm > 0 {
+				changes = append(changes, tempoChange{tick, bpm, 0})
+			}
+		}
+	}
+
+	// stable, so the default tempo is replaced by any tempo set at tick 0
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].tick < changes[j].tick })
+
+	for _, change := range changes {
+		last := len(tempos.changes) - 1
+		if last >= 0 && tempos.changes[last].tick == change.tick {
+			tempos.changes[last].bpm = change.bpm
+			continue
+		}
+
+		change.seconds = tempos.Seconds(change.tick)
+		tempos.changes = append(tempos.changes, change)
+	}
+
+	return tempos, nil
+}
+
+// Creates a tempo map for a midi with a single tempo
+func newConstantTempoMap(ppq int, bpm int) TempoMap {
+	return TempoMap{float64(ppq), []tempoChange{{0, float64(bpm), 0}}}
+}
+
+// Gets the time in seconds at the given tick
+func (t TempoMap) Seconds(tick uint32) float64 {
+	if len(t.changes) == 0 {
+		return float64(tick) / t.ppq * 60 / 120
+	}
+
+	// find the last tempo change at or before the tick
+	i := sort.Search(len(t.changes), func(i int) bool { return t.changes[i].tick > tick }) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	change := t.changes[i]
+	return change.seconds + float64(tick-change.tick)/t.ppq*60/change.bpm
+}
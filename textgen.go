@@ -0,0 +1,170 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Text directions shown in the settings dialog
+const (
+	TextScrolling = "Scrolling" // text reads along time, so it scrolls past as the midi plays
+	TextStatic    = "Static"    // text reads across the keyboard, so it stays in place in a falling notes player
+)
+
+var textDirections = []string{TextScrolling, TextStatic}
+
+// Settings for turning text into notes
+type TextOptions struct {
+	Text      string
+	MinKey    uint8
+	MaxKey    uint8
+	Direction string
+}
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+// 5x7 bitmap font, each row is 5 bits with the leftmost pixel in the highest bit
+// Lowercase letters use the uppercase glyphs, and anything missing is drawn as ?
+var glyphs = map[rune][glyphHeight]uint8{
+	'A':  {0b01110, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001},
+	'B':  {0b11110, 0b10001, 0b10001, 0b11110, 0b10001, 0b10001, 0b11110},
+	'C':  {0b01110, 0b10001, 0b10000, 0b10000, 0b10000, 0b10001, 0b01110},
+	'D':  {0b11110, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b11110},
+	'E':  {0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b11111},
+	'F':  {0b11111, 0b10000, 0b10000, 0b11110, 0b10000, 0b10000, 0b10000},
+	'G':  {0b01110, 0b10001, 0b10000, 0b10111, 0b10001, 0b10001, 0b01111},
+	'H':  {0b10001, 0b10001, 0b10001, 0b11111, 0b10001, 0b10001, 0b10001},
+	'I':  {0b01110, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110},
+	'J':  {0b00111, 0b00010, 0b00010, 0b00010, 0b00010, 0b10010, 0b01100},
+	'K':  {0b10001, 0b10010, 0b10100, 0b11000, 0b10100, 0b10010, 0b10001},
+	'L':  {0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b10000, 0b11111},
+	'M':  {0b10001, 0b11011, 0b10101, 0b10101, 0b10001, 0b10001, 0b10001},
+	'N':  {0b10001, 0b10001, 0b11001, 0b10101, 0b10011, 0b10001, 0b10001},
+	'O':  {0b01110, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110},
+	'P':  {0b11110, 0b10001, 0b10001, 0b11110, 0b10000, 0b10000, 0b10000},
+	'Q':  {0b01110, 0b10001, 0b10001, 0b10001, 0b10101, 0b10010, 0b01101},
+	'R':  {0b11110, 0b10001, 0b10001, 0b11110, 0b10100, 0b10010, 0b10001},
+	'S':  {0b01111, 0b10000, 0b10000, 0b01110, 0b00001, 0b00001, 0b11110},
+	'T':  {0b11111, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00100},
+	'U':  {0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01110},
+	'V':  {0b10001, 0b10001, 0b10001, 0b10001, 0b10001, 0b01010, 0b00100},
+	'W':  {0b10001, 0b10001, 0b10001, 0b10101, 0b10101, 0b10101, 0b01010},
+	'X':  {0b10001, 0b10001, 0b01010, 0b00100, 0b01010, 0b10001, 0b10001},
+	'Y':  {0b10001, 0b10001, 0b10001, 0b01010, 0b00100, 0b00100, 0b00100},
+	'Z':  {0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0b11111},
+	'0':  {0b01110, 0b10001, 0b10011, 0b10101, 0b11001, 0b10001, 0b01110},
+	'1':  {0b00100, 0b01100, 0b00100, 0b00100, 0b00100, 0b00100, 0b01110},
+	'2':  {0b01110, 0b10001, 0b00001, 0b00010, 0b00100, 0b01000, 0b11111},
+	'3':  {0b11111, 0b00010, 0b00100, 0b00010, 0b00001, 0b10001, 0b01110},
+	'4':  {0b00010, 0b00110, 0b01010, 0b10010, 0b11111, 0b00010, 0b00010},
+	'5':  {0b11111, 0b10000, 0b11110, 0b00001, 0b00001, 0b10001, 0b01110},
+	'6':  {0b00110, 0b01000, 0b10000, 0b11110, 0b10001, 0b10001, 0b01110},
+	'7':  {0b11111, 0b00001, 0b00010, 0b00100, 0b01000, 0b01000, 0b01000},
+	'8':  {0b01110, 0b10001, 0b10001, 0b01110, 0b10001, 0b10001, 0b01110},
+	'9':  {0b01110, 0b10001, 0b10001, 0b01111, 0b00001, 0b00010, 0b01100},
+	' ':  {},
+	'.':  {0b00000, 0b00000, 0b00000, 0b00000, 0b00000, 0b01100, 0b01100},
+	',':  {0b00000, 0b00000, 0b00000, 0b00000, 0b01100, 0b00100, 0b01000},
+	'!':  {0b00100, 0b00100, 0b00100, 0b00100, 0b00100, 0b00000, 0b00100},
+	'?':  {0b01110, 0b10001, 0b00001, 0b00010, 0b00100, 0b00000, 0b00100},
+	'-':  {0b00000, 0b00000, 0b00000, 0b11111, 0b00000, 0b00000, 0b00000},
+	'+':  {0b00000, 0b00100, 0b00100, 0b11111, 0b00100, 0b00100, 0b00000},
+	'=':  {0b00000, 0b00000, 0b11111, 0b00000, 0b11111, 0b00000, 0b00000},
+	':':  {0b00000, 0b01100, 0b01100, 0b00000, 0b01100, 0b01100, 0b00000},
+	'\'': {0b00100, 0b00100, 0b01000, 0b00000, 0b00000, 0b00000, 0b00000},
+	'/':  {0b00000, 0b00001, 0b00010, 0b00100, 0b01000, 0b10000, 0b00000},
+	'(':  {0b00010, 0b00100, 0b01000, 0b01000, 0b01000, 0b00100, 0b00010},
+	')':  {0b01000, 0b00100, 0b00010, 0b00010, 0b00010, 0b00100, 0b01000},
+	'#':  {0b01010, 0b01010, 0b11111, 0b01010, 0b11111, 0b01010, 0b01010},
+	'&':  {0b01100, 0b10010, 0b10100, 0b01000, 0b10101, 0b10010, 0b01101},
+}
+
+// Draws the text with the bitmap font, as rows of pixels from top to bottom
+// There is a blank column between each letter, and new lines are treated as spaces
+func textBitmap(text string) [glyphHeight][]bool {
+	var bitmap [glyphHeight][]bool
+
+	for i, char := range []rune(text) {
+		if char == '\n' || char == '\r' || char == '\t' {
+			char = ' '
+		}
+		glyph, ok := glyphs[unicode.ToUpper(char)]
+		if !ok {
+			glyph = glyphs['?']
+		}
+
+		for y := 0; y < glyphHeight; y++ {
+			if i > 0 {
+				bitmap[y] = append(bitmap[y], false)
+			}
+			for x := glyphWidth - 1; x >= 0; x-- {
+				bitmap[y] = append(bitmap[y], glyph[y]&(1<<x) != 0)
+			}
+		}
+	}
+
+	return bitmap
+}
+
+// Creates an array of tracks which spell the text in the piano roll, over the key range and the length of the midi
+// Scrolling text is stretched along time with its rows on the keys, static text is stretched across the keys with
+// its rows in time, with the top row last so it reads the right way up in a falling notes player
+// To keep the note count exact, pixels are skipped evenly if the text needs too many notes, and random notes are
+// added if it needs too few
+// Returns an error if the notes of a track cannot fit under the max polyphony
+func createTextTracks(textOpts TextOptions, opts GenOptions, logger func(format string, a ...any)) ([]smf.Track, error) {
+	var (
+		bitmap = textBitmap(strings.TrimSpace(textOpts.Text))
+		width  = len(bitmap[0])
+		keys   = int(textOpts.MaxKey) - int(textOpts.MinKey) + 1
+		notes  []Note
+	)
+
+	if width > 0 && keys > 0 {
+		// the grid has a row for every key, from the highest key down, and a column for every time slice
+		columns := width
+		lit := func(row, column int) bool { return bitmap[row*glyphHeight/keys][column] }
+		if textOpts.Direction == TextStatic {
+			columns = glyphHeight
+			lit = func(row, column int) bool { return bitmap[glyphHeight-1-column][(keys-1-row)*width/keys] }
+		}
+
+		sliceLength := float64(opts.Ticks) / float64(columns)
+		for column := 0; column < columns; column++ {
+			start := uint32(float64(column) * sliceLength)
+			end := uint32(float64(column+1) * sliceLength)
+			if end <= start {
+				end = start + 1
+			}
+
+			for row := 0; row < keys; row++ {
+				if lit(row, column) {
+					notes = append(notes, Note{Key: textOpts.MaxKey - uint8(row), Velocity: uint8(opts.MaxVelocity), Start: start, End: end})
+				}
+			}
+		}
+	}
+
+	logger("the text needs %d notes", len(notes))
+
+	// skip notes evenly, so the whole text is still readable
+	if len(notes) > opts.NoteCount {
+		kept := make([]Note, opts.NoteCount)
+		for i := range kept {
+			kept[i] = notes[i*len(notes)/opts.NoteCount]
+		}
+		logger("skipped %d notes to keep the note count", len(notes)-len(kept))
+		notes = kept
+	}
+
+	tracks, err := createTracksFromNotes(notes, opts, false, false, logger)
+	if err != nil {
+		return nil, err
+	}
+	return padTracks(tracks, len(notes), opts, logger)
+}
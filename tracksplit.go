@@ -0,0 +1,167 @@
+package main
+
+import (
+	"sort"
+)
+
+// Ways of splitting generated notes into tracks, shown in the settings dialog
+const (
+	TrackSplitFill  = "Fill Tracks"  // tracks of max notes per track, with the notes left over in the last track
+	TrackSplitEven  = "Even"         // as few tracks as fit under max notes per track, all within 1 note of each other
+	TrackSplitCount = "Track Count"  // the number of tracks given, all within 1 note of each other
+	TrackSplitTime  = "Time Regions" // like even, but each track holds the notes of its own part of the midi
+	TrackSplitKeys  = "Key Bands"    // each track holds the notes of its own range of keys, e.g. an octave
+)
+
+var trackSplits = []string{TrackSplitFill, TrackSplitEven, TrackSplitCount, TrackSplitTime, TrackSplitKeys}
+
+// Settings for splitting the generated notes into tracks
+type TrackSplitOptions struct {
+	Split  string
+	Tracks int // number of tracks, for track count
+	Keys   int // number of keys in each band, for key bands
+}
+
+// Gets the number of notes in each track, adding up to the note count
+// Key bands are filled like normal tracks, as the number of notes in each band is only known once they are placed
+func trackSizes(opts GenOptions) []int {
+	var (
+		sizes     []int
+		noteCount = opts.NoteCount
+		maxNotes  = opts.MaxNotesPerTrack
+		tracks    int
+	)
+	if noteCount <= 0 {
+		return nil
+	}
+	if maxNotes < 1 {
+		maxNotes = 1
+	}
+
+	switch opts.TrackSplit.Split {
+	case TrackSplitEven, TrackSplitTime:
+		tracks = (noteCount + maxNotes - 1) / maxNotes
+	case TrackSplitCount:
+		tracks = opts.TrackSplit.Tracks
+		if tracks > noteCount {
+			tracks = noteCount
+		}
+		if tracks < 1 {
+			tracks = 1
+		}
+	default:
+		for remaining := noteCount; remaining > 0; remaining -= maxNotes {
+			if remaining > maxNotes {
+				sizes = append(sizes, maxNotes)
+			} else {
+				sizes = append(sizes, remaining)
+			}
+		}
+		return sizes
+	}
+
+	// the first tracks get one more note until the notes left over are used up
+	for i := 0; i < tracks; i++ {
+		size := noteCount / tracks
+		if i < noteCount%tracks {
+			size++
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+// Gets the number of notes in the largest track
+func largestTrack(opts GenOptions) int {
+	largest := 0
+	for _, size := range trackSizes(opts) {
+		if size > largest {
+			largest = size
+		}
+	}
+	return largest
+}
+
+// Deals the notes of every track out again for the splits which depend on where the notes are, by time or by key
+// Other splits are already made by the number of notes in each track, see trackSizes
+func regroupTracks(trackNotes [][]Note, trackChannels []uint8, opts GenOptions, logger func(format string, a ...any)) [][]Note {
+	switch opts.TrackSplit.Split {
+	case TrackSplitTime:
+		logger("splitting tracks by time")
+		return splitByTime(trackNotes, trackChannels, opts.Channels)
+	case TrackSplitKeys:
+		logger("splitting tracks into bands of %d keys", opts.TrackSplit.Keys)
+		return splitByKeys(trackNotes, opts)
+	}
+	return trackNotes
+}
+
+// Deals every note out again by key, with a track for every band of keys from lowest to highest
+// Bands with more than the max notes per track are split evenly into more tracks, and bands without notes are left out
+func splitByKeys(trackNotes [][]Note, opts GenOptions) [][]Note {
+	var (
+		bands      = map[int][]Note{}
+		bandKeys   []int
+		split      [][]Note
+		trackCount = 0
+		keys       = opts.TrackSplit.Keys
+	)
+	if keys < 1 {
+		keys = 1
+	}
+
+	for _, track := range trackNotes {
+		for _, note := range track {
+			band := int(note.Key) / keys
+			if _, ok := bands[band]; !ok {
+				bandKeys = append(bandKeys, band)
+			}
+			bands[band] = append(bands[band], note)
+		}
+	}
+	sort.Ints(bandKeys)
+
+	for _, band := range bandKeys {
+		bandOpts := opts
+		bandOpts.NoteCount = len(bands[band])
+		bandOpts.TrackSplit = TrackSplitOptions{Split: TrackSplitEven}
+
+		notes := bands[band]
+		for _, size := range trackSizes(bandOpts) {
+			track := notes[:size:size]
+			notes = notes[size:]
+
+			if !opts.Channels.perNote() {
+				channel := opts.Channels.trackChannel(opts.NoteChannel, &trackCount)
+				for i := range track {
+					track[i].Channel = channel
+				}
+			}
+			split = append(split, track)
+			trackCount++
+		}
+	}
+	return split
+}
+
+// Deals every note out again in order of their start, so the first track holds the earliest notes and the last
+// track the latest, keeping the number of notes and the channel of each track
+// Notes keep their own channel if the channel strategy picks a channel for every note
+func splitByTime(trackNotes [][]Note, trackChannels []uint8, channels ChannelStrategy) [][]Note {
+	var notes []Note
+	for _, track := range trackNotes {
+		notes = append(notes, track...)
+	}
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Start < notes[j].Start })
+
+	split := make([][]Note, len(trackNotes))
+	for i, track := range trackNotes {
+		split[i], notes = notes[:len(track):len(track)], notes[len(track):]
+		if !channels.perNote() {
+			for j := range split[i] {
+				split[i][j].Channel = trackChannels[i]
+			}
+		}
+	}
+	return split
+}
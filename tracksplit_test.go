@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrackSizes(t *testing.T) {
+	tests := []struct {
+		name     string
+		notes    int
+		maxNotes int
+		split    TrackSplitOptions
+		want     []int
+	}{
+		{"fill", 10, 4, TrackSplitOptions{Split: TrackSplitFill}, []int{4, 4, 2}},
+		{"fill exactly", 8, 4, TrackSplitOptions{Split: TrackSplitFill}, []int{4, 4}},
+		{"even", 10, 4, TrackSplitOptions{Split: TrackSplitEven}, []int{4, 3, 3}},
+		{"time regions", 10, 4, TrackSplitOptions{Split: TrackSplitTime}, []int{4, 3, 3}},
+		{"track count", 10, 4, TrackSplitOptions{Split: TrackSplitCount, Tracks: 4}, []int{3, 3, 2, 2}},
+		{"more tracks than notes", 3, 4, TrackSplitOptions{Split: TrackSplitCount, Tracks: 5}, []int{1, 1, 1}},
+		{"no notes", 0, 4, TrackSplitOptions{Split: TrackSplitEven}, nil},
+		{"no max notes", 2, 0, TrackSplitOptions{Split: TrackSplitFill}, []int{1, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := trackSizes(GenOptions{NoteCount: test.notes, MaxNotesPerTrack: test.maxNotes, TrackSplit: test.split})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got sizes %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSplitByTime(t *testing.T) {
+	trackNotes := [][]Note{
+		{{Start: 30, Channel: 0}, {Start: 0, Channel: 0}},
+		{{Start: 20, Channel: 1}, {Start: 10, Channel: 1}, {Start: 40, Channel: 1}},
+	}
+
+	tests := []struct {
+		name         string
+		channels     ChannelStrategy
+		wantStarts   [][]uint32
+		wantChannels [][]uint8
+	}{
+		{"channel per track", ChannelStrategy{Strategy: ChannelFromSetting}, [][]uint32{{0, 10}, {20, 30, 40}}, [][]uint8{{0, 0}, {1, 1, 1}}},
+		{"channel per note", ChannelStrategy{Strategy: ChannelKeep}, [][]uint32{{0, 10}, {20, 30, 40}}, [][]uint8{{0, 1}, {1, 0, 1}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var (
+				gotStarts   [][]uint32
+				gotChannels [][]uint8
+			)
+			for _, notes := range splitByTime(trackNotes, []uint8{0, 1}, test.channels) {
+				var (
+					starts   []uint32
+					channels []uint8
+				)
+				for _, note := range notes {
+					starts = append(starts, note.Start)
+					channels = append(channels, note.Channel)
+				}
+				gotStarts = append(gotStarts, starts)
+				gotChannels = append(gotChannels, channels)
+			}
+
+			if !reflect.DeepEqual(gotStarts, test.wantStarts) {
+				t.Errorf("got starts %v, want %v", gotStarts, test.wantStarts)
+			}
+			if !reflect.DeepEqual(gotChannels, test.wantChannels) {
+				t.Errorf("got channels %v, want %v", gotChannels, test.wantChannels)
+			}
+		})
+	}
+}
+
+func TestSplitByKeys(t *testing.T) {
+	notes := [][]Note{{{Key: 61}, {Key: 11}, {Key: 60}, {Key: 0}, {Key: 62}, {Key: 12}}}
+	opts := GenOptions{MaxNotesPerTrack: 2, NoteChannel: "All", TrackSplit: TrackSplitOptions{Split: TrackSplitKeys, Keys: 12}}
+
+	var got [][]uint8
+	for _, track := range splitByKeys(notes, opts) {
+		var keys []uint8
+		for _, note := range track {
+			keys = append(keys, note.Key)
+		}
+		got = append(got, keys)
+	}
+
+	// the band of 60 - 71 has too many notes, so it is split evenly into two tracks
+	want := [][]uint8{{11, 0}, {12}, {61, 60}, {62}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %v, want %v", got, want)
+	}
+}
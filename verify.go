@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Re-reads every file written by createMIDI and checks that it contains what was generated
+// Returns a list of problems, which is empty if the output is correct
+func verifyOutput(midiPath string, parts [][]smf.Track, noteCount int, maxTick uint32) []string {
+	var (
+		problems []string
+		files    = outputFiles(midiPath, len(parts))
+		total    int
+	)
+
+	for i, part := range parts {
+		var expected []int
+		for _, track := range part {
+			expected = append(expected, countTrackNotes(track))
+		}
+
+		notes, partProblems := verifyMIDI(files[i], expected, maxTick)
+		problems = append(problems, partProblems...)
+		total += notes
+	}
+
+	if total != noteCount {
+		problems = append(problems, fmt.Sprintf("expected %d notes in total, found %d", noteCount, total))
+	}
+
+	return problems
+}
+
+// Reads a single midi file and checks its track count, the notes in each track, that every note on has a
+// matching note off, and that nothing goes past maxTick
+// The first track is expected to be the conductor track, so trackNotes only lists the note tracks
+// Returns the number of notes found, and a list of problems
+func verifyMIDI(midiPath string, trackNotes []int, maxTick uint32) (int, []string) {
+	var (
+		problems []string
+		total    int
+	)
+
+	midiData, err := smf.ReadFile(midiPath)
+	if err != nil {
+		return 0, []string{fmt.Sprintf("%s: could not read: %v", midiPath, err)}
+	}
+
+	if len(midiData.Tracks) != len(trackNotes)+1 {
+		problems = append(problems, fmt.Sprintf("%s: expected %d tracks, found %d", midiPath, len(trackNotes)+1, len(midiData.Tracks)))
+	}
+
+	// skip the conductor track
+	for i := 1; i < len(midiData.Tracks); i++ {
+		var (
+			noteOns, noteOffs, unmatched, misordered int
+			tick                                     uint32
+			open                                     = map[[2]uint8][]uint32{} // starts of the notes currently playing, by channel and key
+			channel, key, velocity                   uint8
+		)
+
+		for _, event := range midiData.Tracks[i] {
+			tick += event.Delta
+
+			if event.Message.GetNoteStart(&channel, &key, &velocity) {
+				noteOns++
+				open[[2]uint8{channel, key}] = append(open[[2]uint8{channel, key}], tick)
+			} else if event.Message.GetNoteEnd(&channel, &key) {
+				noteOffs++
+				playing := open[[2]uint8{channel, key}]
+				if len(playing) == 0 {
+					unmatched++
+					continue
+				}
+
+				// a note started on this tick before an earlier note of the same key was turned off,
+				// so the note off cuts off the new note instead of ending the earlier one
+				if playing[0] < tick && playing[len(playing)-1] == tick {
+					misordered++
+				}
+				open[[2]uint8{channel, key}] = playing[1:]
+			}
+		}
+
+		for _, playing := range open {
+			unmatched += len(playing)
+		}
+
+		total += noteOns
+
+		if i-1 < len(trackNotes) && noteOns != trackNotes[i-1] {
+			problems = append(problems, fmt.Sprintf("%s: track %d: expected %d notes, found %d", midiPath, i, trackNotes[i-1], noteOns))
+		}
+		if noteOns != noteOffs || unmatched > 0 {
+			problems = append(problems, fmt.Sprintf("%s: track %d: %d note ons and %d note offs, %d unmatched", midiPath, i, noteOns, noteOffs, unmatched))
+		}
+		if misordered > 0 {
+			problems = append(problems, fmt.Sprintf("%s: track %d: %d notes start before a note of the same key ends on the same tick", midiPath, i, misordered))
+		}
+		if tick > maxTick {
+			problems = append(problems, fmt.Sprintf("%s: track %d: ends at tick %d, past the max of %d", midiPath, i, tick, maxTick))
+		}
+	}
+
+	return total, problems
+}
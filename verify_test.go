@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2"
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+func TestVerifyMIDI(t *testing.T) {
+	// a note starting on the same tick another note of the same key ends, written in the wrong order
+	var misordered smf.Track
+	misordered.Add(0, midi.NoteOn(0, 60, 100))
+	misordered.Add(10, midi.NoteOn(0, 60, 100))
+	misordered.Add(0, midi.NoteOff(0, 60))
+	misordered.Add(10, midi.NoteOff(0, 60))
+	misordered.Close(0)
+
+	// a note left playing
+	var unmatched smf.Track
+	unmatched.Add(0, midi.NoteOn(0, 60, 100))
+	unmatched.Add(10, midi.NoteOn(0, 61, 100))
+	unmatched.Add(10, midi.NoteOff(0, 60))
+	unmatched.Close(0)
+
+	tests := []struct {
+		name     string
+		track    smf.Track
+		notes    int
+		maxTick  uint32
+		problems []string
+	}{
+		{"built", buildTrack([]Note{{Key: 60, Velocity: 100, Start: 10, End: 20}, {Key: 60, Velocity: 100, Start: 0, End: 10}}), 2, 20, nil},
+		{"no length", buildTrack([]Note{{Key: 60, Velocity: 100, Start: 10, End: 10}, {Key: 60, Velocity: 100, Start: 0, End: 10}}), 2, 10, nil},
+		{"misordered", misordered, 2, 20, []string{"track 1: 1 notes start before a note of the same key ends on the same tick"}},
+		{"unmatched", unmatched, 2, 20, []string{"track 1: 2 note ons and 1 note offs, 1 unmatched"}},
+		{"wrong count", buildTrack([]Note{{Key: 60, Velocity: 100, Start: 0, End: 10}}), 2, 10, []string{"track 1: expected 2 notes, found 1"}},
+		{"too long", buildTrack([]Note{{Key: 60, Velocity: 100, Start: 0, End: 20}}), 1, 10, []string{"track 1: ends at tick 20, past the max of 10"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			midiPath := filepath.Join(t.TempDir(), "output.mid")
+			writeMIDI(midiPath, 960, 120, []smf.Track{test.track})
+
+			_, problems := verifyMIDI(midiPath, []int{test.notes}, test.maxTick)
+			if len(problems) != len(test.problems) {
+				t.Fatalf("got problems %q, want %q", problems, test.problems)
+			}
+			for i, problem := range problems {
+				if !strings.HasSuffix(problem, test.problems[i]) {
+					t.Errorf("got problem %q, want %q", problem, test.problems[i])
+				}
+			}
+		})
+	}
+}
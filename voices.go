@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Gets the last tick a note can start on while still being at least the min note length, and how many notes
+// can be played one after another on a single voice (key held at once) in the length of the midi
+func voiceCapacity(opts GenOptions) (int, int) {
+	minNoteLength := opts.MinNoteLength
+	if minNoteLength < 1 {
+		minNoteLength = 1
+	}
+
+	lastStart := opts.Ticks - 1
+	if opts.TrimNotes {
+		lastStart = opts.Ticks - minNoteLength
+	}
+	if lastStart < 0 {
+		return lastStart, 0
+	}
+	return lastStart, lastStart/minNoteLength + 1
+}
+
+// Checks that the largest track can fit under the max polyphony, with every note at least the min note length
+func checkPolyphony(opts GenOptions) error {
+	if opts.MaxPolyphony < 1 {
+		return nil
+	}
+
+	trackNotes := largestTrack(opts)
+
+	_, capacity := voiceCapacity(opts)
+	if trackNotes > capacity*opts.MaxPolyphony {
+		return fmt.Errorf("a track of %d notes cannot fit in %d ticks with at most %d notes at once, the most that fit is %d", trackNotes, opts.Ticks, opts.MaxPolyphony, capacity*opts.MaxPolyphony)
+	}
+	return nil
+}
+
+// Moves and shortens notes so that no more than opts.MaxPolyphony notes are held at once
+// Each note goes to the voice which frees up first, cutting the voice's last note short (down to the min note
+// length) or starting later if it is still held. If that pushes notes past the end of the midi, the notes are
+// instead spread evenly over the voices
+// Notes are never dropped, so an error is returned if there are more notes than can fit in the length of the midi
+func limitPolyphony(notes []Note, opts GenOptions) ([]Note, error) {
+	if opts.MaxPolyphony < 1 || len(notes) == 0 {
+		return notes, nil
+	}
+	if _, capacity := voiceCapacity(opts); capacity == 0 {
+		return nil, fmt.Errorf("a note cannot fit in %d ticks", opts.Ticks)
+	}
+
+	if limited := limitPolyphonyGreedy(notes, opts); limited != nil {
+		return limited, nil
+	}
+	return limitPolyphonyEven(notes, opts)
+}
+
+// Places the notes on the voices in order of their start, returns nil if they do not fit
+func limitPolyphonyGreedy(notes []Note, opts GenOptions) []Note {
+	var (
+		minNoteLength = uint32(opts.MinNoteLength)
+		lastStart, _  = voiceCapacity(opts)
+		limited       = append([]Note(nil), notes...)
+		voices        = make([]int, opts.MaxPolyphony) // index of the last note on each voice, or -1
+	)
+	if minNoteLength < 1 {
+		minNoteLength = 1
+	}
+	for i := range voices {
+		voices[i] = -1
+	}
+
+	sort.SliceStable(limited, func(i, j int) bool { return limited[i].Start < limited[j].Start })
+
+	for i := range limited {
+		note := &limited[i]
+
+		// find the voice which frees up first, either when its last note ends, or when it can be cut short
+		voice, free := 0, ^uint32(0)
+		for v, last := range voices {
+			if last < 0 {
+				voice, free = v, 0
+				break
+			}
+			available := limited[last].End
+			if cut := limited[last].Start + minNoteLength; cut < available {
+				available = cut
+			}
+			if available < free {
+				voice, free = v, available
+			}
+		}
+
+		// every note is at least 1 tick long, as a note with no length is turned off after the next note starts
+		duration := note.End - note.Start
+		if duration < 1 {
+			duration = 1
+		}
+		if note.Start < free {
+			note.Start = free
+		}
+		if int(note.Start) > lastStart {
+			return nil
+		}
+		note.End = note.Start + duration
+		if opts.TrimNotes && note.End > uint32(opts.Ticks) {
+			note.End = uint32(opts.Ticks)
+		}
+
+		// cut the last note short if it is still held
+		if last := voices[voice]; last >= 0 && limited[last].End > note.Start {
+			limited[last].End = note.Start
+		}
+		voices[voice] = i
+	}
+
+	return limited
+}
+
+// Spreads the notes evenly over the voices and the length of the midi, shortening them to fit
+// Returns an error if there are more notes on a voice than ticks they can start on
+func limitPolyphonyEven(notes []Note, opts GenOptions) ([]Note, error) {
+	var (
+		lastStart, _ = voiceCapacity(opts)
+		limited      = append([]Note(nil), notes...)
+		voices       = opts.MaxPolyphony
+		perVoice     = (len(limited) + voices - 1) / voices
+		starts       = lastStart + 1 // number of ticks a note can start on
+	)
+	if perVoice > starts {
+		return nil, fmt.Errorf("a track of %d notes cannot fit in %d ticks with at most %d notes at once", len(notes), opts.Ticks, voices)
+	}
+
+	sort.SliceStable(limited, func(i, j int) bool { return limited[i].Start < limited[j].Start })
+
+	// every voice plays one note in each slot, and each note ends before the next slot starts
+	for i := range limited {
+		var (
+			note  = &limited[i]
+			slot  = i / voices
+			start = slot * starts / perVoice
+			next  = (slot + 1) * starts / perVoice
+		)
+
+		duration := int(note.End - note.Start)
+		if duration > next-start {
+			duration = next - start
+		}
+		if duration < 1 {
+			duration = 1
+		}
+
+		note.Start = uint32(start)
+		note.End = uint32(start + duration)
+	}
+
+	return limited, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.com/gomidi/midi/v2/smf"
+)
+
+// Gets the most notes held at once in a track, reading the events in the order they are played
+func maxHeld(track smf.Track) int {
+	var (
+		held, most             int
+		channel, key, velocity uint8
+	)
+	for _, event := range track {
+		if event.Message.GetNoteStart(&channel, &key, &velocity) {
+			held++
+			if held > most {
+				most = held
+			}
+		} else if event.Message.GetNoteEnd(&channel, &key) {
+			held--
+		}
+	}
+	return most
+}
+
+// Creates notes with random starts and lengths, some with no length at all
+func randomTestNotes(count int, ticks int, maxLength int) []Note {
+	notes := make([]Note, count)
+	for i := range notes {
+		start := rand.Intn(ticks)
+		notes[i] = Note{Key: uint8(rand.Intn(128)), Velocity: 100, Start: uint32(start), End: uint32(start + rand.Intn(maxLength+1))}
+	}
+	return notes
+}
+
+func TestLimitPolyphony(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes []Note
+		opts  GenOptions
+	}{
+		{"monophonic", randomTestNotes(100, 10000, 500), GenOptions{Ticks: 10000, MinNoteLength: 10, MaxPolyphony: 1, TrimNotes: true}},
+		{"three voices", randomTestNotes(300, 10000, 500), GenOptions{Ticks: 10000, MinNoteLength: 10, MaxPolyphony: 3, TrimNotes: true}},
+		{"not trimmed", randomTestNotes(300, 10000, 500), GenOptions{Ticks: 10000, MinNoteLength: 10, MaxPolyphony: 2}},
+		{"no min length", randomTestNotes(500, 1000, 20), GenOptions{Ticks: 1000, MaxPolyphony: 2, TrimNotes: true}},
+		{"too crowded to move later", randomTestNotes(1000, 100, 500), GenOptions{Ticks: 1000, MinNoteLength: 1, MaxPolyphony: 4, TrimNotes: true}},
+		{"one tick each", randomTestNotes(1000, 1000, 0), GenOptions{Ticks: 1000, MaxPolyphony: 1, TrimNotes: true}},
+		{"every tick full", randomTestNotes(40, 10, 10), GenOptions{Ticks: 10, MinNoteLength: 1, MaxPolyphony: 4, TrimNotes: true}},
+		{"no limit", randomTestNotes(100, 100, 100), GenOptions{Ticks: 100, MaxPolyphony: 0, TrimNotes: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limited, err := limitPolyphony(test.notes, test.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(limited) != len(test.notes) {
+				t.Fatalf("got %d notes, want %d", len(limited), len(test.notes))
+			}
+
+			track := buildTrack(limited)
+			if most := maxHeld(track); test.opts.MaxPolyphony > 0 && most > test.opts.MaxPolyphony {
+				t.Errorf("%d notes held at once, the max is %d", most, test.opts.MaxPolyphony)
+			}
+			if test.opts.MaxPolyphony < 1 {
+				if !reflect.DeepEqual(limited, test.notes) {
+					t.Error("notes were changed without a max polyphony")
+				}
+				return
+			}
+			if test.opts.TrimNotes {
+				for _, note := range limited {
+					if note.End > uint32(test.opts.Ticks) {
+						t.Fatalf("note ends at %d, past the length of %d", note.End, test.opts.Ticks)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLimitPolyphonyErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes []Note
+		opts  GenOptions
+		err   string
+	}{
+		{"more notes than ticks", randomTestNotes(11, 10, 10), GenOptions{Ticks: 10, MinNoteLength: 1, MaxPolyphony: 1, TrimNotes: true}, "cannot fit"},
+		{"more notes than voices and ticks", randomTestNotes(41, 10, 10), GenOptions{Ticks: 10, MinNoteLength: 1, MaxPolyphony: 4, TrimNotes: true}, "cannot fit"},
+		{"min length longer than the midi", randomTestNotes(1, 10, 10), GenOptions{Ticks: 10, MinNoteLength: 20, MaxPolyphony: 1, TrimNotes: true}, "cannot fit"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := limitPolyphony(test.notes, test.opts)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestCreateTracksPolyphony(t *testing.T) {
+	opts := GenOptions{
+		NoteCount:        5000,
+		Ticks:            9600,
+		MinNoteLength:    0,
+		MaxNoteLength:    960,
+		MaxNotesPerTrack: 1000,
+		TrimNotes:        true,
+		MinVelocity:      1,
+		MaxVelocity:      127,
+		NoteChannel:      "All",
+		MaxPolyphony:     3,
+	}
+
+	tracks, err := createTracks(opts, t.Logf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, track := range tracks {
+		if most := maxHeld(track); most > opts.MaxPolyphony {
+			t.Errorf("track %d holds %d notes at once, the max is %d", i+1, most, opts.MaxPolyphony)
+		}
+	}
+}
+
+func TestArrangeTracksGate(t *testing.T) {
+	opts := GenOptions{Ticks: 1000, MinNoteLength: 1, MaxPolyphony: 2, TrimNotes: true, Gate: 100}
+
+	for i := 0; i < 20; i++ {
+		tracks, err := arrangeTracks([][]Note{randomTestNotes(200, 1000, 100), randomTestNotes(200, 1000, 100)}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, track := range tracks {
+			if most := maxHeld(track); most > opts.MaxPolyphony {
+				t.Fatalf("track %d holds %d notes at once after the gate, the max is %d", j+1, most, opts.MaxPolyphony)
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Step distributions shown in the settings dialog
+const (
+	StepUniform   = "Uniform"      // every step size up to the max is as likely
+	StepNormal    = "Normal"       // small steps are likely, large steps are rare
+	StepGeometric = "Mostly Small" // each step size is half as likely as the one below it
+)
+
+var stepDistributions = []string{StepUniform, StepNormal, StepGeometric}
+
+// Scales which keys can be snapped to, as semitones above the root
+var scales = map[string][]int{
+	"Chromatic":      {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	"Major":          {0, 2, 4, 5, 7, 9, 11},
+	"Minor":          {0, 2, 3, 5, 7, 8, 10},
+	"Harmonic Minor": {0, 2, 3, 5, 7, 8, 11},
+	"Pentatonic":     {0, 2, 4, 7, 9},
+	"Blues":          {0, 3, 5, 6, 7, 10},
+	"Whole Tone":     {0, 2, 4, 6, 8, 10},
+}
+
+// Scale names in the order shown in the settings dialog, None turns snapping off
+var scaleNames = []string{"None", "Chromatic", "Major", "Minor", "Harmonic Minor", "Pentatonic", "Blues", "Whole Tone"}
+
+var keyNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// Settings for choosing keys with a random walk, instead of at random
+type WalkOptions struct {
+	MinKey       uint8
+	MaxKey       uint8
+	MaxStep      int    // largest interval between two notes, in semitones
+	Distribution string // how likely each step size is
+	Scale        string // scale to snap keys to, or None
+	Root         int    // root of the scale, 0 (C) - 11 (B)
+}
+
+// Creates notes with a random start, length and velocity, where each key is the key of the note before it
+// plus a random step, so the notes form a line which runs up and down the keyboard
+// Steps that would leave the key range bounce back off its edges
+func walkNotes(noteCount int, opts GenOptions, channel uint8) []Note {
+	var (
+		walk   = opts.Walk
+		starts = make([]int, noteCount)
+		notes  []Note
+	)
+
+	// the walk follows time, so the starts are picked first
+	for i := range starts {
+		starts[i] = randomStart(opts)
+	}
+	sort.Ints(starts)
+
+	key := int(walk.MinKey) + rand.Intn(int(walk.MaxKey)-int(walk.MinKey)+1)
+	key = snapKey(key, 1, walk)
+
+	for i, start := range starts {
+		if i > 0 {
+			step := walkStep(walk.MaxStep, walk.Distribution)
+			next := bounce(key+step, int(walk.MinKey), int(walk.MaxKey))
+
+			direction := 1
+			if next < key || (next == key && step < 0) {
+				direction = -1
+			}
+			next = snapKey(next, direction, walk)
+
+			// snapping can land back on the same key, so move on to the next key of the scale instead
+			if next == key && walk.MaxKey > walk.MinKey {
+				next = snapKey(bounce(key+direction, int(walk.MinKey), int(walk.MaxKey)), direction, walk)
+			}
+			key = next
+		}
+
+		notes = append(notes, createNote(start, uint8(key), opts, channel))
+	}
+
+	return notes
+}
+
+// Gets a random step, never 0, of up to maxStep semitones up or down
+func walkStep(maxStep int, distribution string) int {
+	if maxStep < 1 {
+		maxStep = 1
+	}
+
+	var size int
+	switch distribution {
+	case StepNormal:
+		// keep picking until the step fits, most steps are within half the max step
+		for size < 1 || size > maxStep {
+			size = int(math.Round(math.Abs(rand.NormFloat64()) * float64(maxStep) / 2))
+		}
+	case StepGeometric:
+		size = 1
+		for size < maxStep && rand.Intn(2) == 0 {
+			size++
+		}
+	default:
+		size = rand.Intn(maxStep) + 1
+	}
+
+	if rand.Intn(2) == 0 {
+		return -size
+	}
+	return size
+}
+
+// Reflects a key off the edges of the key range, so it always stays inside it
+func bounce(key int, minKey int, maxKey int) int {
+	if key > maxKey {
+		key = maxKey - (key - maxKey)
+	}
+	if key < minKey {
+		key = minKey + (minKey - key)
+	}
+
+	if key > maxKey {
+		key = maxKey
+	}
+	return key
+}
+
+// Moves a key to the closest key in the scale which is inside the key range
+// Ties go the way the walk is going, 1 for up or -1 for down
+func snapKey(key int, direction int, walk *WalkOptions) int {
+	scale, ok := scales[walk.Scale]
+	if !ok {
+		return key
+	}
+
+	inScale := func(k int) bool {
+		if k < int(walk.MinKey) || k > int(walk.MaxKey) {
+			return false
+		}
+		for _, interval := range scale {
+			if ((k-walk.Root)%12+12)%12 == interval {
+				return true
+			}
+		}
+		return false
+	}
+
+	for distance := 0; distance < 128; distance++ {
+		if inScale(key + direction*distance) {
+			return key + direction*distance
+		}
+		if inScale(key - direction*distance) {
+			return key - direction*distance
+		}
+	}
+
+	// the key range has no keys in the scale
+	return key
+}